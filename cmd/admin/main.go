@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/transfer"
+	"snippetbox.lazarmrkic.com/internal/validator"
+)
+
+// "admin" je alat za operatere - obavlja poslove za koje bi inače trebalo direktno mijenjati bazu
+// koristi iste modele i isti "-dsn" flag kao "cmd/web":
+//
+//	go run ./cmd/admin -dsn "web:pass@/snippetbox?parseTime=true" stats
+//	go run ./cmd/admin create-user -name Alice -email alice@example.com -admin
+//	go run ./cmd/admin reset-password -email alice@example.com
+//	go run ./cmd/admin reset-password -email alice@example.com -password-stdin < password.txt
+//	go run ./cmd/admin disable-user -email alice@example.com
+//	go run ./cmd/admin purge-expired -older-than 720h
+//	go run ./cmd/admin revoke-sessions
+//	go run ./cmd/admin export -users -o snippets.jsonl
+//	go run ./cmd/admin import snippets.jsonl
+//
+// lozinke se heširaju podrazumijevanim podešavanjima (bcrypt) - "cmd/web" ih prevodi na svoja podešavanja prilikom prve prijave
+
+const usage = `Usage: admin [-dsn DSN] [-timeout DURATION] <command> [flags]
+
+Commands:
+  create-user      create an activated user (-admin for an administrator)
+  set-role         change a user's role (user|admin)
+  reset-password   set a new password and log the user out everywhere
+  disable-user     disable an account, log the user out everywhere and delete its API tokens
+  enable-user      re-enable a disabled account
+  purge-expired    permanently delete expired snippets (see -include-unnotified)
+  stats            show user, snippet and session counts
+  revoke-sessions  log out every user by clearing the sessions table
+  export           write all snippets (and optionally users) as JSON Lines
+  import           read snippets and users written by export
+
+Run "admin <command> -h" for the flags of a command.
+`
+
+// "application" sadrži modele koje komande koriste
+// "logger" ispisuje napredak dugih komandi (izvoz i uvoz) na "stderr"
+type application struct {
+	users     models.UserModelInterface
+	snippets  models.SnippetModelInterface
+	sessions  models.SessionModelInterface
+	apiTokens models.APITokenModelInterface
+	transfer  models.TransferModelInterface
+	logger    *slog.Logger
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+func main() {
+	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	timeout := flag.Duration("timeout", 30*time.Second, "Maximum duration of a command (export and import are not limited)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	app := &application{
+		users:     &models.UserModel{DB: db},
+		snippets:  &models.SnippetModel{DB: db},
+		sessions:  &models.SessionModel{DB: db},
+		apiTokens: &models.APITokenModel{DB: db},
+		transfer:  &models.TransferModel{DB: db},
+		logger:    slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+	}
+
+	// "Ctrl+C" prekida komandu - uvoz se nakon toga može ponoviti
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// izvoz i uvoz traju onoliko koliko je potrebno za količinu podataka
+	if command := flag.Arg(0); command != "export" && command != "import" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	err = app.run(ctx, flag.Args())
+	if err != nil {
+		// "-h" nije greška - pomoć je već ispisana
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "admin: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// "run" izvršava jednu komandu
+func (app *application) run(ctx context.Context, args []string) error {
+	commands := map[string]func(context.Context, []string) error{
+		"create-user":     app.createUser,
+		"set-role":        app.setRole,
+		"reset-password":  app.resetPassword,
+		"disable-user":    app.disableUser,
+		"enable-user":     app.enableUser,
+		"purge-expired":   app.purgeExpired,
+		"stats":           app.stats,
+		"revoke-sessions": app.revokeSessions,
+		"export":          app.export,
+		"import":          app.importSnippets,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(app.stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return command(ctx, args[1:])
+}
+
+func (app *application) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("admin "+name, flag.ContinueOnError)
+	fs.SetOutput(app.stderr)
+	return fs
+}
+
+// "readPassword" provjerava datu lozinku, ili generiše nasumičnu ukoliko lozinka nije data
+// "generated" govori da lozinku treba ispisati operateru
+func readPassword(plaintext string) (password string, generated bool, err error) {
+	if plaintext != "" {
+		if !validator.MinChars(plaintext, 8) {
+			return "", false, errors.New("the password must be at least 8 characters long")
+		}
+		return plaintext, false, nil
+	}
+
+	password, err = generatePassword()
+	return password, true, err
+}
+
+// "passwordFromStdin" čita lozinku iz prvog reda standardnog ulaza ("-password-stdin")
+// lozinka se namjerno ne navodi kao vrijednost flag-a, jer bi ostala u istoriji "shell"-a i bila vidljiva u "ps" listi
+func (app *application) passwordFromStdin() (string, error) {
+	fmt.Fprint(app.stderr, "Password: ")
+
+	line, err := bufio.NewReader(app.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no password on standard input")
+	}
+
+	return line, nil
+}
+
+// "generatePassword" vraća nasumičnu lozinku od 20 znakova (15 nasumičnih bajtova)
+func generatePassword() (string, error) {
+	b := make([]byte, 15)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// "userByEmail" vraća korisnika, uz razumljiviju poruku kada korisnik ne postoji
+func (app *application) userByEmail(ctx context.Context, email string) (models.User, error) {
+	if email == "" {
+		return models.User{}, errors.New("the -email flag is required")
+	}
+
+	user, err := app.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return models.User{}, fmt.Errorf("no user with email %q", email)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// "createUser" kreira nalog koji je odmah aktiviran - operater garantuje za "email" adresu
+func (app *application) createUser(ctx context.Context, args []string) error {
+	fs := app.flagSet("create-user")
+	name := fs.String("name", "", "Name of the user")
+	email := fs.String("email", "", "Email address of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password from standard input (a random password is generated and printed if omitted)")
+	admin := fs.Bool("admin", false, "Make the user an administrator")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	*name = strings.TrimSpace(*name)
+	*email = strings.TrimSpace(*email)
+	if *name == "" || *email == "" {
+		return errors.New("the -name and -email flags are required")
+	}
+	if !validator.Matches(*email, validator.EmailRX) {
+		return fmt.Errorf("%q is not a valid email address", *email)
+	}
+
+	var plaintext string
+	if *passwordStdin {
+		var err error
+		plaintext, err = app.passwordFromStdin()
+		if err != nil {
+			return err
+		}
+	}
+
+	password, generated, err := readPassword(plaintext)
+	if err != nil {
+		return err
+	}
+
+	id, err := app.users.Insert(ctx, *name, *email, password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			return fmt.Errorf("a user with email %q already exists", *email)
+		}
+		return err
+	}
+
+	err = app.users.Activate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	role := models.RoleUser
+	if *admin {
+		role = models.RoleAdmin
+		err = app.users.SetRole(ctx, id, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(app.stdout, "Created %s #%d <%s>\n", role, id, *email)
+	if generated {
+		fmt.Fprintf(app.stdout, "Password: %s\n", password)
+	}
+
+	return nil
+}
+
+func (app *application) setRole(ctx context.Context, args []string) error {
+	fs := app.flagSet("set-role")
+	email := fs.String("email", "", "Email address of the user")
+	role := fs.String("role", "", "New role (user|admin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !validator.PermittedValue(*role, models.RoleUser, models.RoleAdmin) {
+		return errors.New("the -role flag must equal user or admin")
+	}
+
+	user, err := app.userByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetRole(ctx, user.ID, *role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "User #%d <%s> is now %s\n", user.ID, user.Email, *role)
+	return nil
+}
+
+// "resetPassword" postavlja novu lozinku i odjavljuje sve sesije korisnika
+func (app *application) resetPassword(ctx context.Context, args []string) error {
+	fs := app.flagSet("reset-password")
+	email := fs.String("email", "", "Email address of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the new password from standard input (a random password is generated and printed if omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := app.userByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+
+	var plaintext string
+	if *passwordStdin {
+		plaintext, err = app.passwordFromStdin()
+		if err != nil {
+			return err
+		}
+	}
+
+	password, generated, err := readPassword(plaintext)
+	if err != nil {
+		return err
+	}
+
+	err = app.users.PasswordReset(ctx, user.ID, password)
+	if err != nil {
+		return err
+	}
+
+	err = app.sessions.RevokeAllForUser(ctx, user.ID, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Password reset for user #%d <%s>\n", user.ID, user.Email)
+	if generated {
+		fmt.Fprintf(app.stdout, "Password: %s\n", password)
+	}
+
+	return nil
+}
+
+// "disableUser" onemogućava nalog, odjavljuje sve sesije korisnika i briše njegove API tokene (isto kao administratorska stranica)
+func (app *application) disableUser(ctx context.Context, args []string) error {
+	fs := app.flagSet("disable-user")
+	email := fs.String("email", "", "Email address of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := app.userByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetDisabled(ctx, user.ID, true)
+	if err != nil {
+		return err
+	}
+
+	err = app.sessions.RevokeAllForUser(ctx, user.ID, "")
+	if err != nil {
+		return err
+	}
+
+	err = app.apiTokens.DeleteAllForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Disabled user #%d <%s>\n", user.ID, user.Email)
+	return nil
+}
+
+func (app *application) enableUser(ctx context.Context, args []string) error {
+	fs := app.flagSet("enable-user")
+	email := fs.String("email", "", "Email address of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := app.userByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetDisabled(ctx, user.ID, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Enabled user #%d <%s>\n", user.ID, user.Email)
+	return nil
+}
+
+// "purgeExpired" trajno briše istekle "snippet"-e
+// "-older-than" ostavlja nedavno istekle "snippet"-e, npr. kako bi se mogli vratiti na zahtjev korisnika
+// "snippet.expired" događaj šalje "cmd/web" proces, pa se "snippet"-i za koje on još nije poslat preskaču
+// ukoliko "cmd/web" ne radi (ili "webhook"-i nisu potrebni), "-include-unnotified" ih briše bez tog događaja
+func (app *application) purgeExpired(ctx context.Context, args []string) error {
+	fs := app.flagSet("purge-expired")
+	olderThan := fs.Duration("older-than", 0, "Only delete snippets that expired at least this long ago")
+	includeUnnotified := fs.Bool("include-unnotified", false, "Also delete snippets whose snippet.expired webhook event hasn't been sent yet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *olderThan < 0 {
+		return errors.New("the -older-than flag cannot be negative")
+	}
+
+	count, skipped, err := app.snippets.DeleteExpired(ctx, *olderThan, *includeUnnotified)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Deleted %d expired snippets\n", count)
+	if skipped > 0 {
+		fmt.Fprintf(app.stdout, "Skipped %d expired snippets whose snippet.expired webhook event the web server hasn't sent yet (use -include-unnotified to delete them anyway)\n", skipped)
+	}
+	return nil
+}
+
+func (app *application) stats(ctx context.Context, args []string) error {
+	fs := app.flagSet("stats")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	users, err := app.users.Counts(ctx)
+	if err != nil {
+		return err
+	}
+
+	snippets, err := app.snippets.Counts(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := app.sessions.CountActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Users\t%d\n", users.Total)
+	fmt.Fprintf(tw, "  activated\t%d\n", users.Activated)
+	fmt.Fprintf(tw, "  disabled\t%d\n", users.Disabled)
+	fmt.Fprintf(tw, "  admins\t%d\n", users.Admins)
+	fmt.Fprintf(tw, "  joined in the last 7 days\t%d\n", users.LastWeek)
+	fmt.Fprintf(tw, "Snippets\t%d\n", snippets.Total)
+	fmt.Fprintf(tw, "  active\t%d\n", snippets.Active)
+	fmt.Fprintf(tw, "  public and active\t%d\n", snippets.Public)
+	fmt.Fprintf(tw, "  expired\t%d\n", snippets.Expired)
+	fmt.Fprintf(tw, "Active sessions\t%d\n", sessions)
+
+	return tw.Flush()
+}
+
+// "revokeSessions" odjavljuje sve korisnike, npr. nakon sigurnosnog incidenta
+// traži potvrdu, osim ako je naveden "-yes"
+func (app *application) revokeSessions(ctx context.Context, args []string) error {
+	fs := app.flagSet("revoke-sessions")
+	yes := fs.Bool("yes", false, "Don't ask for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*yes {
+		fmt.Fprint(app.stderr, "This logs out every user. Type \"yes\" to continue: ")
+		var answer string
+		fmt.Fscanln(app.stdin, &answer)
+		if answer != "yes" {
+			return errors.New("aborted")
+		}
+	}
+
+	count, err := app.sessions.RevokeAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Revoked %d sessions\n", count)
+	return nil
+}
+
+// "export" upisuje podatke u fajl ("-o") ili na standardni izlaz
+func (app *application) export(ctx context.Context, args []string) error {
+	fs := app.flagSet("export")
+	output := fs.String("o", "-", "Output file (- for stdout)")
+	users := fs.Bool("users", false, "Include users (without password hashes)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w := app.stdout
+	if *output != "-" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// zapisi se upisuju preko "buffer"-a, kako svaki red ne bi bio poseban sistemski poziv
+	bw := bufio.NewWriter(w)
+
+	stats, err := transfer.Export(ctx, bw, app.transfer, transfer.Options{IncludeUsers: *users}, app.logger)
+	if err != nil {
+		return err
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stderr, "Exported %d users and %d snippets\n", stats.Users, stats.Snippets)
+	return nil
+}
+
+// "importSnippets" čita podatke iz navedenog fajla ili sa standardnog ulaza
+func (app *application) importSnippets(ctx context.Context, args []string) error {
+	fs := app.flagSet("import")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := app.stdin
+	if fs.NArg() > 1 {
+		return errors.New("usage: admin import [file]")
+	}
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	stats, err := transfer.Import(ctx, bufio.NewReader(r), app.transfer, app.logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Users: %d inserted, %d renumbered, %d already existed\n",
+		stats.Users[models.ImportInserted], stats.Users[models.ImportRenumbered], stats.Users[models.ImportExisting])
+	fmt.Fprintf(app.stdout, "Snippets: %d inserted, %d renumbered, %d already existed\n",
+		stats.Snippets[models.ImportInserted], stats.Snippets[models.ImportRenumbered], stats.Snippets[models.ImportExisting])
+	if stats.UnknownAuthors > 0 {
+		fmt.Fprintf(app.stdout, "%d snippets were imported without an author, because the author doesn't exist here\n", stats.UnknownAuthors)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "stubUsers", "stubSessions" i "stubAPITokens" čuvaju podatke u memoriji
+// metode koje testovi ne koriste ostaju neimplementirane (ugrađeni "nil" interfejs)
+type stubUsers struct {
+	models.UserModelInterface
+	users map[int]models.User
+}
+
+func (m *stubUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, models.ErrNoRecord
+}
+
+func (m *stubUsers) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	u, ok := m.users[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	u.Disabled = disabled
+	m.users[id] = u
+	return nil
+}
+
+type stubSessions struct {
+	models.SessionModelInterface
+	sessions map[int]int
+}
+
+func (m *stubSessions) RevokeAllForUser(ctx context.Context, userID int, exceptToken string) error {
+	delete(m.sessions, userID)
+	return nil
+}
+
+type stubAPITokens struct {
+	models.APITokenModelInterface
+	tokens map[int]int
+}
+
+func (m *stubAPITokens) DeleteAllForUser(ctx context.Context, userID int) error {
+	delete(m.tokens, userID)
+	return nil
+}
+
+func TestPasswordFromStdin(t *testing.T) {
+	tests := []struct {
+		name    string
+		stdin   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Line", stdin: "pa$$word\n", want: "pa$$word"},
+		{name: "CRLF", stdin: "pa$$word\r\n", want: "pa$$word"},
+		{name: "No newline", stdin: "pa$$word", want: "pa$$word"},
+		{name: "Only first line", stdin: "pa$$word\nsecond\n", want: "pa$$word"},
+		{name: "Spaces are kept", stdin: " pa$$ word \n", want: " pa$$ word "},
+		{name: "Empty", stdin: "", wantErr: true},
+		{name: "Empty line", stdin: "\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			app := &application{stdin: strings.NewReader(tt.stdin), stderr: &stderr}
+
+			password, err := app.passwordFromStdin()
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, password, tt.want)
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	password, generated, err := readPassword("pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, password, "pa$$word")
+	assert.Equal(t, generated, false)
+
+	_, _, err = readPassword("short")
+	assert.Equal(t, err != nil, true)
+
+	first, generated, err := readPassword("")
+	assert.NilError(t, err)
+	assert.Equal(t, generated, true)
+	assert.Equal(t, len(first), 20)
+
+	// svaka generisana lozinka je drugačija
+	second, _, err := readPassword("")
+	assert.NilError(t, err)
+	assert.Equal(t, first != second, true)
+}
+
+// "stubSnippets" ima "expired" istekla "snippet"-a, od kojih "unnotified" još nije obrađeno
+type stubSnippets struct {
+	models.SnippetModelInterface
+	expired    int
+	unnotified int
+}
+
+func (m *stubSnippets) DeleteExpired(ctx context.Context, olderThan time.Duration, includeUnnotified bool) (int, int, error) {
+	if includeUnnotified {
+		return m.expired, 0, nil
+	}
+	return m.expired - m.unnotified, m.unnotified, nil
+}
+
+func TestDisableUser(t *testing.T) {
+	users := &stubUsers{users: map[int]models.User{
+		1: {ID: 1, Email: "alice@example.com"},
+		2: {ID: 2, Email: "bob@example.com"},
+	}}
+	sessions := &stubSessions{sessions: map[int]int{1: 2, 2: 1}}
+	apiTokens := &stubAPITokens{tokens: map[int]int{1: 3, 2: 1}}
+
+	var stdout bytes.Buffer
+	app := &application{users: users, sessions: sessions, apiTokens: apiTokens, stdout: &stdout, stderr: &stdout}
+
+	err := app.run(context.Background(), []string{"disable-user", "-email", "alice@example.com"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, users.users[1].Disabled, true)
+	assert.Equal(t, sessions.sessions[1], 0)
+	assert.Equal(t, apiTokens.tokens[1], 0)
+
+	// ostali korisnici zadržavaju sesije i tokene
+	assert.Equal(t, users.users[2].Disabled, false)
+	assert.Equal(t, sessions.sessions[2], 1)
+	assert.Equal(t, apiTokens.tokens[2], 1)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "Skips unnotified",
+			args: []string{"purge-expired"},
+			want: "Deleted 3 expired snippets\n" +
+				"Skipped 2 expired snippets whose snippet.expired webhook event the web server hasn't sent yet (use -include-unnotified to delete them anyway)\n",
+		},
+		{
+			name: "Include unnotified",
+			args: []string{"purge-expired", "-include-unnotified"},
+			want: "Deleted 5 expired snippets\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			app := &application{snippets: &stubSnippets{expired: 5, unnotified: 2}, stdout: &stdout, stderr: &stdout}
+
+			err := app.run(context.Background(), tt.args)
+			assert.NilError(t, err)
+			assert.Equal(t, stdout.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// "client" poziva JSON API aplikacije ("/api/v1/...") sa API tokenom korisnika
+
+// "snippet" je oblik u kom API vraća "snippet" (vidjeti "snippetJSON" u "cmd/web/api.go")
+type snippet struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+	Author     *struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"author"`
+	Team *struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+	URL string `json:"url"`
+}
+
+type metadata struct {
+	Page         int `json:"page"`
+	PageSize     int `json:"page_size"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+// "apiError" je greška koju je vratio server
+// "Message" je ili tekst greške ili mapa grešaka po poljima (kod "422" odgovora)
+type apiError struct {
+	Status  int
+	Message any
+}
+
+func (e *apiError) Error() string {
+	switch msg := e.Message.(type) {
+	case string:
+		return fmt.Sprintf("%s (%d)", msg, e.Status)
+	case map[string]any:
+		// polja se sortiraju, kako bi poruka uvijek izgledala isto
+		fields := make([]string, 0, len(msg))
+		for field, problem := range msg {
+			fields = append(fields, fmt.Sprintf("%s: %v", field, problem))
+		}
+		sort.Strings(fields)
+		return fmt.Sprintf("%s (%d)", strings.Join(fields, "; "), e.Status)
+	default:
+		return fmt.Sprintf("unexpected response status %d", e.Status)
+	}
+}
+
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// "newClient" kreira klijenta za aplikaciju na adresi "baseURL"
+// "caFile" je putanja do PEM sertifikata kom se dodatno vjeruje - npr. "./tls/cert.pem" za lokalni, "self-signed" sertifikat
+func newClient(baseURL string, token string, caFile string) (*client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid server URL %q", baseURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// sistemski sertifikati ostaju validni - "caFile" se samo dodaje na listu
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// "do" šalje zahtjev i dekodira JSON odgovor u "dst" ("nil" ukoliko odgovor nije potreban)
+func (c *client) do(ctx context.Context, method string, path string, body any, dst any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var envelope struct {
+			Error any `json:"error"`
+		}
+		// ukoliko tijelo nije JSON (npr. greška "proxy"-ja), poruka ostaje prazna
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope)
+		return &apiError{Status: resp.StatusCode, Message: envelope.Error}
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+type me struct {
+	User struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"user"`
+	Token struct {
+		Name    string    `json:"name"`
+		Scopes  []string  `json:"scopes"`
+		Expires time.Time `json:"expires"`
+	} `json:"token"`
+}
+
+func (c *client) me(ctx context.Context) (me, error) {
+	var resp me
+	err := c.do(ctx, http.MethodGet, "/api/v1/me", nil, &resp)
+	return resp, err
+}
+
+// "createInput" odgovara tijelu "POST /api/v1/snippets" zahtjeva
+// prazna polja se izostavljaju, pa server koristi podrazumijevane vrijednosti
+type createInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Expires    int    `json:"expires,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+	TeamID     int    `json:"team_id,omitempty"`
+}
+
+func (c *client) create(ctx context.Context, input createInput) (snippet, error) {
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/v1/snippets", input, &resp)
+	return resp.Snippet, err
+}
+
+func (c *client) get(ctx context.Context, id int) (snippet, error) {
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/snippets/%d", id), nil, &resp)
+	return resp.Snippet, err
+}
+
+// "list" vraća jednu stranicu "snippet"-a korisnika, odnosno tima ukoliko "teamID" nije "0"
+func (c *client) list(ctx context.Context, page int, teamID int) ([]snippet, metadata, error) {
+	query := url.Values{}
+	query.Set("page", fmt.Sprint(page))
+	if teamID != 0 {
+		query.Set("team", fmt.Sprint(teamID))
+	}
+
+	var resp struct {
+		Snippets []snippet `json:"snippets"`
+		Metadata metadata  `json:"metadata"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/snippets?"+query.Encode(), nil, &resp)
+	return resp.Snippets, resp.Metadata, err
+}
+
+func (c *client) delete(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/snippets/%d", id), nil, nil)
+}
+
+// "isNotFound" olakšava prepoznavanje "404" odgovora
+func isNotFound(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// "snippetctl" je klijent za komandnu liniju - kreira, čita, izlistava i briše "snippet"-e preko JSON API-ja
+// primjer:
+//
+//	snippetctl login -url https://localhost:4000 -ca ./tls/cert.pem
+//	echo "Climb Mount Fuji" | snippetctl create -title "O snail" -expires 7
+//	snippetctl get 42
+//	snippetctl list
+//	snippetctl delete 42
+//
+// adresa servera, token i sertifikat se čuvaju u konfiguracionom fajlu nakon "login" komande
+
+const usage = `Usage: snippetctl <command> [flags] [arguments]
+
+Commands:
+  login              store the server URL and API token
+  create [file ...]  create a snippet from files (or stdin) and print its URL
+  get <id>           print the raw content of a snippet
+  list               list your snippets (or a team's snippets with -team)
+  delete <id> ...    delete snippets
+
+Run "snippetctl <command> -h" for the flags of a command.
+`
+
+// "config" se čuva kao JSON u "~/.config/snippetctl/config.json" (zavisno od OS-a)
+// fajl sadrži token, pa ga može čitati samo vlasnik ("0600")
+type config struct {
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	CACert string `json:"ca_cert,omitempty"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".snippetctl.json"
+	}
+	return filepath.Join(dir, "snippetctl", "config.json")
+}
+
+// "loadConfig" vraća praznu konfiguraciju ukoliko fajl ne postoji
+func loadConfig(path string) (config, error) {
+	var cfg config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+func saveConfig(path string, cfg config) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0o600)
+}
+
+// "connection" sadrži flag-ove zajedničke za sve komande
+// vrijednosti flag-ova imaju prednost nad konfiguracionim fajlom, a "SNIPPETCTL_TOKEN" nad sačuvanim tokenom
+type connection struct {
+	configPath string
+	url        string
+	token      string
+	caCert     string
+}
+
+func (c *connection) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", defaultConfigPath(), "Path to the config file")
+	fs.StringVar(&c.url, "url", "", "Server URL, e.g. https://localhost:4000 (overrides the config file)")
+	fs.StringVar(&c.token, "token", "", "API token (overrides SNIPPETCTL_TOKEN and the config file)")
+	fs.StringVar(&c.caCert, "ca", "", "PEM certificate to trust, e.g. ./tls/cert.pem for a self-signed server (overrides the config file)")
+}
+
+// "resolve" spaja flag-ove, promjenljive okruženja i konfiguracioni fajl
+func (c *connection) resolve() (config, error) {
+	cfg, err := loadConfig(c.configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	if c.url != "" {
+		cfg.URL = c.url
+	}
+	if token := os.Getenv("SNIPPETCTL_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	if c.token != "" {
+		cfg.Token = c.token
+	}
+	if c.caCert != "" {
+		cfg.CACert = c.caCert
+	}
+
+	return cfg, nil
+}
+
+func (c *connection) client() (*client, error) {
+	cfg, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, errors.New(`not logged in - run "snippetctl login" or pass -url and -token`)
+	}
+
+	return newClient(cfg.URL, cfg.Token, cfg.CACert)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		// "-h" nije greška - pomoć je već ispisana
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "snippetctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// "run" izvršava jednu komandu
+// ulaz i izlaz su parametri, kako bi se komande mogle testirati bez pravog terminala
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "login":
+		return runLogin(ctx, args[1:], stdin, stdout, stderr)
+	case "create":
+		return runCreate(ctx, args[1:], stdin, stdout, stderr)
+	case "get":
+		return runGet(ctx, args[1:], stdout, stderr)
+	case "list":
+		return runList(ctx, args[1:], stdout, stderr)
+	case "delete":
+		return runDelete(ctx, args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("snippetctl "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// "runLogin" provjerava token preko "/api/v1/me" i čuva ga u konfiguracionom fajlu
+// ukoliko "-token" nije naveden, token se čita sa standardnog ulaza (kako ne bi ostao u istoriji "shell"-a)
+func runLogin(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var conn connection
+
+	fs := newFlagSet("login", stderr)
+	conn.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := conn.resolve()
+	if err != nil {
+		return err
+	}
+	if cfg.URL == "" {
+		return errors.New("the -url flag is required")
+	}
+
+	if conn.token == "" {
+		fmt.Fprint(stderr, "API token: ")
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		cfg.Token = strings.TrimSpace(line)
+	}
+	if cfg.Token == "" {
+		return errors.New("no API token given")
+	}
+
+	c, err := newClient(cfg.URL, cfg.Token, cfg.CACert)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.me(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = saveConfig(conn.configPath, cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Logged in to %s as %s <%s> (scopes: %s)\n", cfg.URL, info.User.Name, info.User.Email, strings.Join(info.Token.Scopes, ", "))
+	return nil
+}
+
+// "runCreate" spaja sadržaj navedenih fajlova (ili standardnog ulaza) u jedan "snippet" i ispisuje njegov URL
+// "-" kao ime fajla označava standardni ulaz
+func runCreate(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var conn connection
+	var input createInput
+
+	fs := newFlagSet("create", stderr)
+	conn.register(fs)
+	fs.StringVar(&input.Title, "title", "", "Snippet title (defaults to the file name when a single file is given)")
+	fs.IntVar(&input.Expires, "expires", 0, "Days until the snippet expires: 1, 7 or 365 (server default: 365)")
+	fs.StringVar(&input.Visibility, "visibility", "", "public, private or team (server default: public)")
+	fs.IntVar(&input.TeamID, "team", 0, "ID of the team the snippet belongs to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var content strings.Builder
+	for _, name := range files {
+		var b []byte
+		var err error
+		if name == "-" {
+			b, err = io.ReadAll(stdin)
+		} else {
+			b, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return err
+		}
+		content.Write(b)
+	}
+	input.Content = content.String()
+
+	if input.Title == "" {
+		if len(files) != 1 || files[0] == "-" {
+			return errors.New("the -title flag is required when reading from stdin or several files")
+		}
+		input.Title = filepath.Base(files[0])
+	}
+
+	c, err := conn.client()
+	if err != nil {
+		return err
+	}
+
+	s, err := c.create(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, s.URL)
+	return nil
+}
+
+// "runGet" ispisuje sadržaj "snippet"-a tačno onako kako je sačuvan, pa se može preusmjeriti u fajl
+func runGet(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	var conn connection
+
+	fs := newFlagSet("get", stderr)
+	conn.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: snippetctl get <id>")
+	}
+
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := conn.client()
+	if err != nil {
+		return err
+	}
+
+	s, err := c.get(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("snippet #%d not found", id)
+		}
+		return err
+	}
+
+	_, err = io.WriteString(stdout, s.Content)
+	return err
+}
+
+// "runList" ispisuje "snippet"-e kao tabelu
+// bez "-all" se ispisuje samo jedna stranica, a broj stranica se ispisuje na "stderr"
+func runList(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	var conn connection
+
+	fs := newFlagSet("list", stderr)
+	conn.register(fs)
+	page := fs.Int("page", 1, "Page to list")
+	all := fs.Bool("all", false, "List all pages")
+	teamID := fs.Int("team", 0, "List the snippets of this team instead of your own")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := conn.client()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tVISIBILITY\tEXPIRES")
+
+	var meta metadata
+	for p := *page; ; p++ {
+		var snippets []snippet
+		snippets, meta, err = c.list(ctx, p, *teamID)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range snippets {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.ID, s.Title, s.Visibility, s.Expires.UTC().Format("2006-01-02 15:04"))
+		}
+
+		if !*all || p >= meta.LastPage {
+			break
+		}
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if !*all && meta.LastPage > 1 {
+		fmt.Fprintf(stderr, "page %d of %d (%d snippets) - use -page or -all for more\n", meta.Page, meta.LastPage, meta.TotalRecords)
+	}
+
+	return nil
+}
+
+// "runDelete" briše jedan ili više "snippet"-a i zaustavlja se na prvoj grešci
+func runDelete(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	var conn connection
+
+	fs := newFlagSet("delete", stderr)
+	conn.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: snippetctl delete <id> ...")
+	}
+
+	ids := make([]int, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	c, err := conn.client()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err = c.delete(ctx, id)
+		if err != nil {
+			if isNotFound(err) {
+				return fmt.Errorf("snippet #%d not found", id)
+			}
+			return err
+		}
+		fmt.Fprintf(stdout, "Deleted snippet #%d\n", id)
+	}
+
+	return nil
+}
+
+// "parseID" prihvata i oblik "#42", kako se ID prikazuje na stranicama aplikacije
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid snippet ID %q", s)
+	}
+	return id, nil
+}
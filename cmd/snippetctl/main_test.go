@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"strings"
+	"testing"
+)
+
+// "newTestServer" pokreće HTTPS server sa "self-signed" sertifikatom (kao "./tls" u razvoju)
+// i upisuje sertifikat u PEM fajl, kako bi ga klijent koristio preko "-ca" flag-a
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, string) {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(handler)
+	t.Cleanup(ts.Close)
+
+	caFile := filepath.Join(t.TempDir(), "cert.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600)
+	assert.NilError(t, err)
+
+	return ts, caFile
+}
+
+func TestCreate(t *testing.T) {
+	var got createInput
+
+	ts, caFile := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Equal(t, r.URL.Path, "/api/v1/snippets")
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"snippet": {"id": 7, "url": "https://localhost:4000/snippet/view/7"}}`))
+	})
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"create", "-url", ts.URL, "-token", "secret", "-ca", caFile, "-config", filepath.Join(t.TempDir(), "none.json"), "-title", "O snail", "-expires", "7"}
+
+	err := run(context.Background(), args, strings.NewReader("Climb Mount Fuji\n"), &stdout, &stderr)
+	assert.NilError(t, err)
+
+	assert.Equal(t, stdout.String(), "https://localhost:4000/snippet/view/7\n")
+	assert.Equal(t, got.Title, "O snail")
+	assert.Equal(t, got.Content, "Climb Mount Fuji\n")
+	assert.Equal(t, got.Expires, 7)
+	assert.Equal(t, got.Visibility, "")
+}
+
+func TestLoginStoresConfig(t *testing.T) {
+	ts, caFile := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid or missing authentication token"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/me":
+			w.Write([]byte(`{"user": {"id": 1, "name": "Alice", "email": "alice@example.com"}, "token": {"scopes": ["snippets:read"]}}`))
+		case "/api/v1/snippets/3":
+			w.Write([]byte(`{"snippet": {"id": 3, "content": "raw\r\ncontent"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "the requested resource could not be found"}`))
+		}
+	})
+
+	configPath := filepath.Join(t.TempDir(), "snippetctl", "config.json")
+	var stdout, stderr bytes.Buffer
+
+	// pogrešan token se ne čuva
+	err := run(context.Background(), []string{"login", "-url", ts.URL, "-ca", caFile, "-config", configPath}, strings.NewReader("wrong\n"), &stdout, &stderr)
+	assert.Equal(t, err.Error(), "invalid or missing authentication token (401)")
+	_, err = os.Stat(configPath)
+	assert.Equal(t, os.IsNotExist(err), true)
+
+	// token se čita sa standardnog ulaza
+	err = run(context.Background(), []string{"login", "-url", ts.URL, "-ca", caFile, "-config", configPath}, strings.NewReader("secret\n"), &stdout, &stderr)
+	assert.NilError(t, err)
+
+	cfg, err := loadConfig(configPath)
+	assert.NilError(t, err)
+	assert.Equal(t, cfg, config{URL: ts.URL, Token: "secret", CACert: caFile})
+
+	info, err := os.Stat(configPath)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+	// naredne komande koriste sačuvanu konfiguraciju
+	stdout.Reset()
+	err = run(context.Background(), []string{"get", "-config", configPath, "#3"}, nil, &stdout, &stderr)
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "raw\r\ncontent")
+
+	err = run(context.Background(), []string{"get", "-config", configPath, "4"}, nil, &stdout, &stderr)
+	assert.Equal(t, err.Error(), "snippet #4 not found")
+}
+
+func TestUntrustedCertificate(t *testing.T) {
+	ts, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	c, err := newClient(ts.URL, "secret", "")
+	assert.NilError(t, err)
+
+	_, err = c.me(context.Background())
+	assert.Equal(t, err != nil && strings.Contains(err.Error(), "certificate"), true)
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	err := &apiError{Status: 422, Message: map[string]any{"title": "This field cannot be blank", "content": "This field cannot be blank"}}
+	assert.Equal(t, err.Error(), "content: This field cannot be blank; title: This field cannot be blank (422)")
+
+	err = &apiError{Status: 502}
+	assert.Equal(t, err.Error(), "unexpected response status 502")
+}
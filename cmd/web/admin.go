@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/transfer"
+	"strconv"
+	"time"
+)
+
+// "handler"-i za administratorski dio aplikacije
+// sve rute prolaze kroz "requireAdmin", pa ovdje ne provjeravamo ulogu korisnika
+
+// broj redova po stranici na administratorskim listama
+const adminPageSize = 25
+
+// broj najnovijih registracija na početnoj administratorskoj stranici
+const adminRecentSignups = 10
+
+// "adminDashboard" prikazuje statistiku i najnovije registracije
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userCounts, err := app.users.Counts(ctx)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippetCounts, err := app.snippets.Counts(ctx)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	users, err := app.users.List(ctx, adminRecentSignups, 0)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserCounts = userCounts
+	data.SnippetCounts = snippetCounts
+	data.Users = users
+
+	app.render(w, r, http.StatusOK, "admin.tmpl", data)
+}
+
+// "adminUsers" prikazuje sve korisnike, stranicu po stranicu
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	counts, err := app.users.Counts(ctx)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	p := pagination{Page: readPage(r), PageSize: adminPageSize, TotalRecords: counts.Total}
+	if p.Page > p.LastPage() {
+		app.notFound(w)
+		return
+	}
+
+	users, err := app.users.List(ctx, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "admin_users.tmpl", data)
+}
+
+// "adminSnippets" prikazuje sve "snippet"-e, uključujući i one koji su istekli
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	counts, err := app.snippets.Counts(ctx)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	p := pagination{Page: readPage(r), PageSize: adminPageSize, TotalRecords: counts.Total}
+	if p.Page > p.LastPage() {
+		app.notFound(w)
+		return
+	}
+
+	snippets, err := app.snippets.List(ctx, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "admin_snippets.tmpl", data)
+}
+
+// "adminUserDisablePost" onemogućava nalog i odmah odjavljuje sve njegove sesije
+func (app *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserDisabled(w, r, true)
+}
+
+func (app *application) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserDisabled(w, r, false)
+}
+
+func (app *application) adminSetUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, ok := app.readPostFormID(w, r)
+	if !ok {
+		return
+	}
+
+	adminID := app.authenticatedUserID(r)
+
+	// administrator ne može da onemogući sam sebe - inače bi mogao ostati bez ijednog administratora
+	if id == adminID {
+		app.sessionManager.Put(r.Context(), "flash", "You can't disable your own account.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.users.SetDisabled(ctx, id, disabled)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if disabled {
+		// "authenticate" i "authenticateToken" već odbijaju onemogućene korisnike
+		// sesije i API tokene ipak brišemo, kako ponovno omogućavanje naloga ne bi vratilo stari pristup
+		err = app.userSessions.RevokeAllForUser(ctx, id, "")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.apiTokens.DeleteAllForUser(ctx, id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.logger.Info("user disabled", "user_id", id, "admin_id", adminID)
+		app.sessionManager.Put(r.Context(), "flash", "The account has been disabled.")
+	} else {
+		app.logger.Info("user enabled", "user_id", id, "admin_id", adminID)
+		app.sessionManager.Put(r.Context(), "flash", "The account has been enabled.")
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// "adminSnippetDeletePost" trajno briše "snippet" (npr. zbog zloupotrebe)
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readPostFormID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.snippets.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Info("snippet deleted", "snippet_id", id, "admin_id", app.authenticatedUserID(r))
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been deleted.")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// "adminExport" šalje sve "snippet"-e (i korisnike, uz "?users=true") kao JSON Lines fajl
+// zapisi se šalju čim se pročitaju iz baze, pa se ni velika baza ne učitava cijela u memoriju
+func (app *application) adminExport(w http.ResponseWriter, r *http.Request) {
+	opts := transfer.Options{IncludeUsers: r.URL.Query().Get("users") == "true"}
+
+	filename := fmt.Sprintf("snippetbox-%s.jsonl", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	adminID := app.authenticatedUserID(r)
+	app.logger.Info("export started", "admin_id", adminID, "users", opts.IncludeUsers)
+
+	// nakon prvog zapisa zaglavlja su već poslata - greška se može samo zabilježiti, a preuzeti fajl ostaje nepotpun
+	_, err := transfer.Export(r.Context(), w, app.transfer, opts, app.logger)
+	if err != nil {
+		app.logger.Error("export failed", "admin_id", adminID, "error", err.Error())
+	}
+}
+
+// "adminImportPost" uvozi fajl koji je napravio izvoz (preko administratorske stranice ili "cmd/admin" alata)
+func (app *application) adminImportPost(w http.ResponseWriter, r *http.Request) {
+	// "nosurf" je već pročitao "multipart" formu, kako bi provjerio CSRF token
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			app.sessionManager.Put(r.Context(), "flash", "Choose a file to import.")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	adminID := app.authenticatedUserID(r)
+	app.logger.Info("import started", "admin_id", adminID)
+
+	stats, err := transfer.Import(r.Context(), file, app.transfer, app.logger)
+	// uvoz piše direktno u bazu, pa keširana lista najnovijih "snippet"-a ne bi sadržala uvezene javne "snippet"-e
+	// lista se briše i kada uvoz stane zbog greške, jer su zapisi prije greške već uvezeni
+	if cached, ok := app.snippets.(interface{ PurgeLatest() }); ok {
+		cached.PurgeLatest()
+	}
+	if err != nil {
+		// zapisi prije greške su već uvezeni - ponovni uvoz istog fajla ih preskače
+		app.logger.Error("import failed", "admin_id", adminID, "error", err.Error())
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The import stopped at an error (%s). Fix the file and import it again - already imported records are skipped.", err))
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", importSummary(stats))
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// "importSummary" opisuje rezultat uvoza u jednoj rečenici, za "flash" poruku
+func importSummary(stats transfer.ImportStats) string {
+	summary := fmt.Sprintf("Imported %d users (%d already existed) and %d snippets (%d with a new ID, %d already existed).",
+		stats.Users[models.ImportInserted]+stats.Users[models.ImportRenumbered], stats.Users[models.ImportExisting],
+		stats.Snippets[models.ImportInserted]+stats.Snippets[models.ImportRenumbered], stats.Snippets[models.ImportRenumbered], stats.Snippets[models.ImportExisting])
+
+	if stats.UnknownAuthors > 0 {
+		summary += fmt.Sprintf(" %d snippets have no author, because the author doesn't exist here.", stats.UnknownAuthors)
+	}
+
+	return summary
+}
+
+// "adminMetrics" prikazuje statistiku aplikacije (npr. keša) u JSON formatu
+// "expvar.Handler()" se namjerno ne koristi - on prikazuje i "cmdline" (lozinke i tajne iz flag-ova) i "memstats"
+func (app *application) adminMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(app.metrics.String()))
+}
+
+// "readPostFormID" čita "id" polje iz forme
+// ukoliko polje nije ispravno, šalje "400 Bad Request" i vraća "false"
+func (app *application) readPostFormID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
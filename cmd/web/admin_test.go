@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+)
+
+func TestAdminMetrics(t *testing.T) {
+	metrics := new(expvar.Map).Init()
+	metrics.Set("snippetCache", expvar.Func(func() any {
+		return map[string]int{"hits": 3}
+	}))
+	app := &application{metrics: metrics}
+
+	rr := httptest.NewRecorder()
+	app.adminMetrics(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	var body map[string]json.RawMessage
+	assert.NilError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+
+	// globalne "expvar" vrijednosti ("cmdline" sadrži flag-ove sa lozinkama) se ne prikazuju
+	_, ok := body["cmdline"]
+	assert.Equal(t, ok, false)
+	_, ok = body["memstats"]
+	assert.Equal(t, ok, false)
+	assert.Equal(t, string(body["snippetCache"]), `{"hits":3}`)
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"strconv"
+)
+
+// "handler"-i za JSON API
+// zahtjevi prolaze kroz "authenticateToken" i "requireToken", pa je token uvijek dostupan u "request context"-u
+
+// "apiMe" vraća podatke o vlasniku tokena i dozvolama koje token ima
+// korisno je za skripte, kako bi provjerile da li je token ispravan
+func (app *application) apiMe(w http.ResponseWriter, r *http.Request) {
+	token := r.Context().Value(apiTokenContextKey).(models.APIToken)
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.invalidTokenResponse(w, r)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	data := map[string]any{
+		"user": map[string]any{
+			"id":      user.ID,
+			"name":    user.Name,
+			"email":   user.Email,
+			"created": user.Created,
+		},
+		"token": map[string]any{
+			"name":    token.Name,
+			"scopes":  token.Scopes,
+			"expires": token.Expires,
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// broj "snippet"-a po stranici u API odgovorima
+const apiPageSize = 20
+
+// tijelo zahtjeva za kreiranje "snippet"-a
+// "expires" i "visibility" nisu obavezni - podrazumijevane vrijednosti su iste kao na HTML formi
+type apiSnippetCreateInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Expires    int    `json:"expires"`
+	Visibility string `json:"visibility"`
+	TeamID     int    `json:"team_id"`
+}
+
+// tijelo zahtjeva za izmjenu "snippet"-a
+// polja su "pointer"-i, kako bismo razlikovali izostavljeno polje od prazne vrijednosti
+type apiSnippetUpdateInput struct {
+	Title      *string `json:"title"`
+	Content    *string `json:"content"`
+	Visibility *string `json:"visibility"`
+}
+
+// "snippetJSON" pretvara "snippet" u oblik koji vraća API
+// "author" i "team" su "null" kada "snippet" nema autora, odnosno ne pripada timu
+func (app *application) snippetJSON(s models.Snippet) map[string]any {
+	var author, team any
+	if s.UserID != 0 {
+		author = map[string]any{"id": s.UserID, "name": s.AuthorName}
+	}
+	if s.TeamID != 0 {
+		team = map[string]any{"id": s.TeamID, "name": s.TeamName}
+	}
+
+	return map[string]any{
+		"id":         s.ID,
+		"title":      s.Title,
+		"content":    s.Content,
+		"created":    s.Created,
+		"expires":    s.Expires,
+		"visibility": s.Visibility,
+		"author":     author,
+		"team":       team,
+		"url":        fmt.Sprintf("%s/snippet/view/%d", app.baseURL, s.ID),
+	}
+}
+
+// "readIDParamJSON" čita "id" parametar putanje
+// ukoliko parametar nije ispravan, šalje "404" u JSON obliku i vraća "false"
+func (app *application) readIDParamJSON(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFoundJSON(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) notFoundJSON(w http.ResponseWriter, r *http.Request) {
+	app.errorJSON(w, r, http.StatusNotFound, "the requested resource could not be found")
+}
+
+// "readSnippetJSON" čita "snippet" iz "id" parametra putanje
+// "snippet" koji korisnik ne smije da vidi se tretira kao nepostojeći
+func (app *application) readSnippetJSON(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, ok := app.readIDParamJSON(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFoundJSON(w, r)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	visible, err := app.canViewSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return models.Snippet{}, false
+	}
+	if !visible {
+		app.notFoundJSON(w, r)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// "readEditableSnippetJSON" je JSON verzija "readEditableSnippet" helper-a
+func (app *application) readEditableSnippetJSON(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.readSnippetJSON(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	canEdit, err := app.canEditSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return models.Snippet{}, false
+	}
+	if !canEdit {
+		app.errorJSON(w, r, http.StatusForbidden, "you don't have permission to modify this snippet")
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// "apiSnippets" vraća "snippet"-e vlasnika tokena koji još nisu istekli, stranicu po stranicu
+// uz "?team=ID" vraća "snippet"-e tima, ukoliko je korisnik njegov član
+func (app *application) apiSnippets(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	teamID := 0
+	if v := r.URL.Query().Get("team"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			app.errorJSON(w, r, http.StatusBadRequest, map[string]string{"team": "must be a positive integer"})
+			return
+		}
+		teamID = id
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// "snippet"-e tima vide samo članovi tima
+	role, err := app.teamRole(ctx, teamID, userID)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	var counts models.SnippetCounts
+	if teamID != 0 {
+		if role == "" {
+			app.notFoundJSON(w, r)
+			return
+		}
+		counts, err = app.snippets.CountsForTeam(ctx, teamID)
+	} else {
+		counts, err = app.snippets.CountsForUser(ctx, userID)
+	}
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	p := pagination{Page: readPage(r), PageSize: apiPageSize, TotalRecords: counts.Active}
+
+	// stranica nakon posljednje vraća praznu listu, a ne "404" - tako skripte lakše prolaze kroz sve stranice
+	var snippets []models.Snippet
+	if p.Page <= p.LastPage() {
+		if teamID != 0 {
+			snippets, err = app.snippets.ListForTeam(ctx, teamID, p.PageSize, p.Offset())
+		} else {
+			snippets, err = app.snippets.ListActiveForUser(ctx, userID, p.PageSize, p.Offset())
+		}
+		if err != nil {
+			app.serverErrorJSON(w, r, err)
+			return
+		}
+	}
+
+	list := make([]map[string]any, 0, len(snippets))
+	for _, s := range snippets {
+		list = append(list, app.snippetJSON(s))
+	}
+
+	data := map[string]any{
+		"snippets": list,
+		"metadata": map[string]any{
+			"page":          p.Page,
+			"page_size":     p.PageSize,
+			"last_page":     p.LastPage(),
+			"total_records": p.TotalRecords,
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// "apiSnippetView" vraća jedan "snippet" - važe ista pravila vidljivosti kao za "snippetView"
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetJSON(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, map[string]any{"snippet": app.snippetJSON(snippet)}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// "apiSnippetCreate" kreira "snippet"
+// podaci se validiraju istim pravilima kao HTML forma, a greške se vraćaju po poljima: {"error": {"title": "..."}}
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetCreateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:      input.Title,
+		Content:    input.Content,
+		Expires:    input.Expires,
+		Visibility: input.Visibility,
+		TeamID:     input.TeamID,
+	}
+	if form.Expires == 0 {
+		form.Expires = 365
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	role, err := app.teamRole(ctx, form.TeamID, userID)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+	form.validate(role)
+
+	if !form.Valid() {
+		app.errorJSON(w, r, http.StatusUnprocessableEntity, form.FieldErrors)
+		return
+	}
+
+	id, err := app.snippets.Insert(ctx, userID, form.TeamID, form.Title, form.Content, form.Expires, form.Visibility)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	app.dispatchWebhookEvent(models.EventSnippetCreated, id)
+	app.publishSnippetCreated(id)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	err = app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": app.snippetJSON(snippet)}, headers)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// "apiSnippetUpdate" mijenja samo polja koja su poslata u tijelu zahtjeva
+// važe ista pravila kao za "snippetEditPost"
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readEditableSnippetJSON(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiSnippetUpdateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := snippetEditForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Visibility: snippet.Visibility,
+	}
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	if input.Content != nil {
+		form.Content = *input.Content
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+
+	form.validate(snippet)
+	if !form.Valid() {
+		app.errorJSON(w, r, http.StatusUnprocessableEntity, form.FieldErrors)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.snippets.Update(ctx, snippet.ID, form.Title, form.Content, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFoundJSON(w, r)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	app.dispatchWebhookEvent(models.EventSnippetEdited, snippet.ID)
+
+	snippet.Title = form.Title
+	snippet.Content = form.Content
+	snippet.Visibility = form.Visibility
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"snippet": app.snippetJSON(snippet)}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// "apiSnippetDelete" trajno briše "snippet" - dozvola je ista kao za izmjenu
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readEditableSnippetJSON(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.snippets.Delete(ctx, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFoundJSON(w, r)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"message": "snippet successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
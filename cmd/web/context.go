@@ -5,3 +5,15 @@ type contextKey string
 // u ovoj promjenjivoj će se čuvati ključ koji je vezan za "authentication status"
 // njega koristimo za čuvanje i vađenje "authentication status"-a
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// "ID" ulogovanog korisnika - postavlja ga "authenticate" (za sesije) ili "authenticateToken" (za API tokene)
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// "true" ukoliko ulogovani korisnik ima "admin" ulogu - postavlja ga "authenticate"
+const isAdminContextKey = contextKey("isAdmin")
+
+// API token kojim je zahtjev autentifikovan - koristi se za provjeru dozvola ("scope"-ova)
+const apiTokenContextKey = contextKey("apiToken")
+
+// "true" ukoliko je vlasnik API tokena potvrdio "email" adresu - postavlja ga "authenticateToken"
+const isActivatedContextKey = contextKey("isActivated")
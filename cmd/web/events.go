@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/pubsub"
+	"time"
+)
+
+// "/events" šalje nove javne "snippet"-e preko Server-Sent Events ("text/event-stream")
+// početna stranica ih prikazuje bez osvježavanja (vidjeti "ui/static/js/main.js")
+
+// događaj koji se šalje kada se kreira javni "snippet"
+const eventSnippetCreated = "snippet.created"
+
+// komentar se šalje svakih "eventsHeartbeat", kako "proxy"-ji ne bi zatvorili konekciju zbog neaktivnosti
+const eventsHeartbeat = 30 * time.Second
+
+// broj poruka koje mogu da čekaju na jednog sporog klijenta
+const eventsBufferSize = 16
+
+// "snippetEvent" je oblik u kom se "snippet" šalje browser-u
+// vrijeme kreiranja je već formatirano kao na početnoj stranici ("humanDate")
+type snippetEvent struct {
+	ID      int          `json:"id"`
+	Title   string       `json:"title"`
+	Author  *eventAuthor `json:"author"`
+	Created string       `json:"created"`
+}
+
+type eventAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// "events" drži konekciju otvorenom i šalje događaje dok god je klijent povezan
+func (app *application) events(w http.ResponseWriter, r *http.Request) {
+	// "WriteTimeout" servera bi prekinuo konekciju nakon nekoliko sekundi, pa za ovu konekciju rok uklanjamo
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	messages, unsubscribe, err := app.broker.Subscribe()
+	if err != nil {
+		if errors.Is(err, pubsub.ErrTooManySubscribers) {
+			w.Header().Set("Retry-After", "60")
+			app.clientError(w, http.StatusServiceUnavailable)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// "nginx" inače čuva odgovor u "buffer"-u, pa događaji ne bi stizali odmah
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// "retry" govori browser-u koliko milisekundi da čeka prije ponovnog povezivanja
+	fmt.Fprint(w, "retry: 5000\n\n")
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, msg.Data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+
+		// greška znači da je klijent zatvorio konekciju
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// "publishSnippetCreated" objavljuje novi "snippet" otvorenim "/events" konekcijama, ukoliko je javan
+// kao i "dispatchWebhookEvent", radi u pozadini, kako ne bi usporio odgovor korisniku
+func (app *application) publishSnippetCreated(snippetID int) {
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), app.queryTimeout)
+		defer cancel()
+
+		s, err := app.snippets.Get(ctx, snippetID)
+		if err != nil {
+			app.logger.Error(err.Error(), "event", eventSnippetCreated, "snippet", snippetID)
+			return
+		}
+
+		// početna stranica prikazuje samo javne "snippet"-e
+		if s.Visibility != models.VisibilityPublic {
+			return
+		}
+
+		event := snippetEvent{ID: s.ID, Title: s.Title, Created: humanDate(s.Created)}
+		if s.UserID != 0 {
+			event.Author = &eventAuthor{ID: s.UserID, Name: s.AuthorName}
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			app.logger.Error(err.Error(), "event", eventSnippetCreated, "snippet", snippetID)
+			return
+		}
+
+		app.broker.Publish(pubsub.Message{Event: eventSnippetCreated, Data: data})
+	})
+}
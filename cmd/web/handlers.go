@@ -1,13 +1,20 @@
 package main
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"net/url"
+	"slices"
 	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/oidc"
+	"snippetbox.lazarmrkic.com/internal/totp"
 	"snippetbox.lazarmrkic.com/internal/validator"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ovaj "struct" predstavlja podatke unutar forme i greške tokom validacije za njena polja
@@ -17,16 +24,62 @@ type snippetCreateForm struct {
 	Title   string `form:"title"`
 	Content string `form:"content"`
 	Expires int    `form:"expires"`
+	// "public", "private" ili "team"
+	Visibility string `form:"visibility"`
+	// "0" za lični "snippet", inače "ID" tima u kojem korisnik ima ulogu "owner" ili "editor"
+	TeamID int `form:"team"`
 	// obrisaćemo "fieldErrors" polje
 	// umjesto njega, "ugradićemo" Validator struct
 	// to znači da će "snippetCreateForm" naslijediti sva polja i metode unutar njega
 	validator.Validator `form:"-"`
 }
 
+// "validate" provjerava polja forme - ista pravila važe i za HTML formu i za JSON API
+// "role" je uloga korisnika u izabranom timu (prazan string ukoliko nije član ili tim nije izabran)
+func (form *snippetCreateForm) validate(role string) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityPrivate, models.VisibilityTeam), "visibility", "This field must equal public, private or team")
+
+	// "snippet" tima mogu da kreiraju samo vlasnici i urednici tima
+	// lični "snippet" ne može biti vidljiv timu, a "snippet" tima ne može biti privatan
+	if form.TeamID != 0 {
+		form.CheckField(models.CanEdit(role), "team", "You can't create snippets in this team")
+		form.CheckField(form.Visibility != models.VisibilityPrivate, "visibility", "Team snippets must be public or visible to the team")
+	} else {
+		form.CheckField(form.Visibility != models.VisibilityTeam, "visibility", "Choose a team to share this snippet with")
+	}
+}
+
+// izmjena ne mijenja rok trajanja ni tim kojem "snippet" pripada
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// "validate" primjenjuje ista pravila kao prilikom kreiranja
+// vidljivost zavisi od toga da li "snippet" pripada timu
+func (form *snippetEditForm) validate(snippet models.Snippet) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	if snippet.TeamID != 0 {
+		form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityTeam), "visibility", "This field must equal public or team")
+	} else {
+		form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityPrivate), "visibility", "This field must equal public or private")
+	}
+}
+
+// "Code" je kod pozivnice - obavezan samo u "invite-only" režimu
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Code                string `form:"code"`
 	validator.Validator `form:"-"`
 }
 
@@ -36,11 +89,95 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+type userForgotPasswordForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// "Token" se prenosi kroz skriveno polje forme, jer ga korisnik dobija unutar linka iz mejla
+type userResetPasswordForm struct {
+	Token               string `form:"token"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// unos koda iz "authenticator" aplikacije tokom prijave
+// umjesto njega, korisnik može da unese i jedan od kodova za oporavak
+type userLoginTOTPForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// "Secret" i "URI" se ne čitaju iz forme - tajni ključ se do potvrde čuva u sesiji
+type accountTOTPEnableForm struct {
+	Code                string `form:"code"`
+	Secret              string `form:"-"`
+	URI                 string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+type accountTOTPDisableForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// "Scopes" se popunjava iz više "checkbox" polja sa istim imenom
+type accountTokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	Expires             int      `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+// "HasScope" se koristi u templejtu, kako bi izabrane dozvole ostale označene nakon greške u validaciji
+func (f accountTokenCreateForm) HasScope(scope string) bool {
+	return slices.Contains(f.Scopes, scope)
+}
+
+// "Snippets" određuje šta se dešava sa "snippet"-ima: "delete" ili "anonymize" (javni ostaju, ali bez autora)
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"`
+	Confirm             bool   `form:"confirm"`
+	validator.Validator `form:"-"`
+}
+
+// "Email" nije obavezan - ukoliko je unesen, pozivnicu može da iskoristi samo ta adresa
+type accountInvitationCreateForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// kvota za pozivnice se računa za posljednjih 30 dana
+const invitationQuotaPeriod = 30 * 24 * time.Hour
+
+// maksimalan broj pogrešnih TOTP kodova prije nego što korisnik mora ponovo da unese lozinku
+const maxTOTPAttempts = 5
+
+// broj kodova za oporavak koji se generišu prilikom uključivanja TOTP-a
+const recoveryCodeCount = 10
+
+// link za reset lozinke važi jedan sat, a link za aktivaciju naloga tri dana
+const (
+	passwordResetTTL = time.Hour
+	activationTTL    = 3 * 24 * time.Hour
+)
+
 // BITNO:
 // svi "handler"-i trebaju da postanu metode "application" struct-a
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// novi "httprouter" tačno ubada "/" putanju, pa zbog toga uklanjamo "r.URL.Path != "/" provjeru
-	snippets, err := app.snippets.Latest()
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, err := app.snippets.Latest(ctx)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -69,7 +206,10 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -79,6 +219,24 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// privatni "snippet" vidi samo autor, a "snippet" tima i članovi tima
+	// ostali dobijaju "404", kako se ne bi otkrilo da "snippet" postoji
+	visible, err := app.canViewSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	canEdit, err := app.canEditSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// BITNO - naredni kod više nije potreban, pokriven je u "newTemplateData" metodi
 	// "snippetView" handler treba da učita "flash" poruku (ukoliko ona postoji za trenutnog korisnika)
 	// i nakon toga, da je proslijedi odgovarajućem HTML templejtu
@@ -89,6 +247,7 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.CanEdit = canEdit
 
 	//data.Flash = flash
 
@@ -96,12 +255,24 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// timovi u kojima korisnik smije da kreira "snippet"-e
+	teams, err := app.editableTeams(ctx, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
+	data.Teams = teams
 
 	// prosljeđivanje "snippetCreateForm" instance u templejt
 	// na ovaj način možemo da postavimo "default" vrijednost za formu, mimo "expires" polja
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    365,
+		Visibility: models.VisibilityPublic,
 	}
 
 	app.render(w, r, http.StatusOK, "create.tmpl", data)
@@ -132,29 +303,48 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 	// odličan blog post koji sadrži generalne metode za validaciju:
 	// https://www.alexedwards.net/blog/validation-snippets-for-go#email-validation
-	// validacija:
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	// validacija (pravila se nalaze u "validate" metodi, jer ih koristi i JSON API):
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	role, err := app.teamRole(ctx, form.TeamID, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	form.validate(role)
 
 	// ukoliko neke od grešaka postoje, onda treba nanovo prikazati "create.tmpl" templejt
 	// dinamički podaci će biti proslijeđeni u "Form" polje
 	// takođe, treba poslati 402 HTTP status kod - koji pokazuje da je došlo do greške prilikom validacije
 	if !form.Valid() {
+		teams, err := app.editableTeams(ctx, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
 		data := app.newTemplateData(r)
+		data.Teams = teams
 		data.Form = form
 		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
 	// prosljeđivanje podataka ka bazi
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// autor "snippet"-a je ulogovani korisnik
+	id, err := app.snippets.Insert(ctx, userID, form.TeamID, form.Title, form.Content, form.Expires, form.Visibility)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	app.dispatchWebhookEvent(models.EventSnippetCreated, id)
+	// otvorene početne stranice dobijaju novi "snippet" preko "/events"
+	app.publishSnippetCreated(id)
+
 	// preko "Put()" metode dodajemo string vrijednost i odgovarajući ključ ("flash")
 	// "r.Context()" označava trenutni "request context"
 	// gruba definicija - nešto gdje "session manager" PRIVREMENO čuva informacije, dok "handler"-i upravljaju zahtjevima
@@ -164,9 +354,181 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+// "readEditableSnippet" čita "snippet" iz "id" parametra putanje i provjerava da li ga korisnik smije mijenjati
+// ukoliko ne smije, šalje odgovarajući odgovor i vraća "false"
+func (app *application) readEditableSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return models.Snippet{}, false
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	// korisnik koji ne vidi "snippet" dobija "404", a korisnik koji ga vidi, ali ne smije da ga mijenja - "403"
+	visible, err := app.canViewSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return models.Snippet{}, false
+	}
+	if !visible {
+		app.notFound(w)
+		return models.Snippet{}, false
+	}
+
+	canEdit, err := app.canEditSnippet(ctx, r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return models.Snippet{}, false
+	}
+	if !canEdit {
+		app.clientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// "snippetEdit" prikazuje formu za izmjenu "snippet"-a
+// lične "snippet"-e mijenja autor, a "snippet"-e tima vlasnici i urednici tima
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readEditableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readEditableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(snippet)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.snippets.Update(ctx, snippet.ID, form.Title, form.Content, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.dispatchWebhookEvent(models.EventSnippetEdited, snippet.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// broj "snippet"-a po stranici na profilu korisnika
+const profilePageSize = 10
+
+// "userProfile" prikazuje javni profil korisnika - ime, datum registracije i javne "snippet"-e
+// "email" adresa se namjerno ne prikazuje
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// profili onemogućenih naloga nisu javni
+	if user.Disabled {
+		app.notFound(w)
+		return
+	}
+
+	counts, err := app.snippets.CountsForUser(ctx, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	p := pagination{Page: readPage(r), PageSize: profilePageSize, TotalRecords: counts.Public}
+	if p.Page > p.LastPage() {
+		app.notFound(w)
+		return
+	}
+
+	snippets, err := app.snippets.ListForUser(ctx, id, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Snippets = snippets
+	data.SnippetCounts = counts
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "profile.tmpl", data)
+}
+
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
+	// link iz pozivnice sadrži kod, pa ga odmah upisujemo u formu
+	data.Form = userSignupForm{
+		Code: r.URL.Query().Get("code"),
+	}
 	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
@@ -184,6 +546,18 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(app.signup.domainAllowed(form.Email), "email", "Signups are limited to addresses at "+strings.Join(app.signup.AllowedDomains, ", "))
+	if app.signup.Mode == signupInvite {
+		form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	}
+
+	// u zatvorenom režimu forma se ne prikazuje, ali zahtjev i dalje može stići direktno
+	if app.signup.Mode == signupClosed {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusForbidden, "signup.tmpl", data)
+		return
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -192,10 +566,40 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// pozivnica se "rezerviše" prije kreiranja naloga, kako isti kod ne bi mogao da se iskoristi dva puta
+	var invitationID int
+	if app.signup.Mode == signupInvite {
+		invitationID, err = app.invitations.Redeem(ctx, form.Code, form.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidInvitation) {
+				form.AddFieldErrorKey("code", "This invitation code is invalid, expired or has already been used")
+
+				data := app.newTemplateData(r)
+				data.Form = form
+				app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
 	// dodavanje novog korisnika u bazu
 	// ukoliko korisnik sa datim mejlom već postoji, onda treba prikazati "error message" na formi i ponovo je izrenderovati
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(ctx, form.Name, form.Email, form.Password)
 	if err != nil {
+		// nalog nije kreiran, pa pozivnica ostaje važeća
+		if invitationID != 0 {
+			releaseErr := app.invitations.Release(ctx, invitationID)
+			if releaseErr != nil {
+				app.serverError(w, r, releaseErr)
+				return
+			}
+		}
+
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldErrorKey("email", "Email address is already in use")
 
@@ -205,14 +609,28 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		} else {
 			app.serverError(w, r, err)
 		}
+		return
+	}
+
+	if invitationID != 0 {
+		err = app.invitations.SetUsedBy(ctx, invitationID, id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// novi nalog nije aktiviran dok korisnik ne otvori link iz mejla
+	err = app.sendActivationEmail(ctx, models.User{ID: id, Name: form.Name, Email: form.Email})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	// ako je dodavanje prošlo OK, onda treba dodati "flash" poruku u sesiju, kako bi se potvrdilo da je "sign-up" prošao
-	app.sessionManager.Put(r.Context(), "flash", "Your sign-up was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Your sign-up was successful. We've sent you an email to verify your address. Please log in.")
 	// nakon ovoga, vrši se redirekcija ka "login" stranici
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-
-	return
 }
 
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
@@ -241,52 +659,1302 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// prije provjere lozinke, provjeravamo da li su "email" adresa ili IP adresa privremeno blokirane
+	wait, err := app.loginRetryAfter(ctx, r, form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanDuration(wait)))
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "login.tmpl", data)
+		return
+	}
+
 	// provjera da li su "user" kredencijali ispravni
 	// ukoliko nisu - treba dodati "non-field error message" i ponovo prikazati "login" stranicu
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(ctx, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			// neuspješan pokušaj se bilježi i za "email" adresu i za IP adresu
+			err = app.loginFailed(ctx, r, form.Email)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		} else if errors.Is(err, models.ErrAccountDisabled) {
+			form.AddNonFieldError("Your account has been disabled. Please contact an administrator.")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusForbidden, "login.tmpl", data)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// pozivom "RenewToken()" metode nad trenutnom sesijom se mijenja Session ID
-	// uvijek treba generisati novi Session ID kada se kod nekog korisnika stanje autentifikacije ili privilegija promijeni
-	err = app.sessionManager.RenewToken(r.Context())
+	user, err := app.users.Get(ctx, id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// u sesiju se dodaje ID-a trenutnog korisnika
-	// nakon toga je "ulogovan"
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	// ukoliko korisnik ima uključen TOTP, lozinka nije dovoljna
+	// "authenticatedUserID" se ne upisuje u sesiju dok korisnik ne unese i kod iz "authenticator" aplikacije
+	// brojač neuspješnih prijava ostaje do kraja prijave, jer pogrešni kodovi takođe povećavaju brojač
+	if user.TOTPEnabled {
+		err = app.startTOTPLogin(r, id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 
-	// preusmjeravanje korisnika na "createSnippet" stranicu
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
-}
+		http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+		return
+	}
 
-// kako bismo izlogovali korisnika, dovoljno je da samo uklonimo "authenticatedUserID" vrijednost iz sesije
-func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	// za svaki slučaj osvježavamo "Session ID"
-	err := app.sessionManager.RenewToken(r.Context())
+	err = app.loginSucceeded(ctx, form.Email)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// nakon toga, brišemo "authenticatedUserID" iz "session data", kako bismo izlogovali korisnika
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
-	// prikaz "flash" poruke, kako bismo obavjestili korisnika da je izlogovan
-	app.sessionManager.Put(r.Context(), "flash", "You've been successfully logged out!")
-
-	// preusmjeravanje ka "home" stranici
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	err = app.logIn(r, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// preusmjeravanje korisnika na stranicu koju je ranije tražio (ili na "createSnippet" stranicu)
+	http.Redirect(w, r, app.redirectPathAfterLogin(r), http.StatusSeeOther)
+}
+
+// drugi korak prijave, za korisnike koji imaju uključen TOTP
+func (app *application) userLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	if app.sessionManager.GetInt(r.Context(), "totpPendingUserID") == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userLoginTOTPForm{}
+	app.render(w, r, http.StatusOK, "login_totp.tmpl", data)
+}
+
+func (app *application) userLoginTOTPPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "totpPendingUserID")
+	if userID == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userLoginTOTPForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login_totp.tmpl", data)
+		return
+	}
+
+	// broj pokušaja je ograničen - nakon toga, korisnik mora ponovo da unese lozinku
+	attempts := app.sessionManager.GetInt(r.Context(), "totpAttempts") + 1
+	if attempts > maxTOTPAttempts {
+		app.sessionManager.Remove(r.Context(), "totpPendingUserID")
+		app.sessionManager.Remove(r.Context(), "totpAttempts")
+		app.sessionManager.Put(r.Context(), "flash", "Too many incorrect codes. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "totpAttempts", attempts)
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// pogrešni kodovi se broje zajedno sa pogrešnim lozinkama, pa ponovna prijava lozinkom ne daje nove pokušaje
+	wait, err := app.loginRetryAfter(ctx, r, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		app.sessionManager.Remove(r.Context(), "totpPendingUserID")
+		app.sessionManager.Remove(r.Context(), "totpAttempts")
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanDuration(wait)))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	ok, err := app.checkSecondFactor(ctx, userID, form.Code)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !ok {
+		err = app.loginFailed(ctx, r, user.Email)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		form.AddFieldErrorKey("code", "This code is incorrect")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login_totp.tmpl", data)
+		return
+	}
+
+	err = app.loginSucceeded(ctx, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "totpPendingUserID")
+	app.sessionManager.Remove(r.Context(), "totpAttempts")
+
+	err = app.logIn(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, app.redirectPathAfterLogin(r), http.StatusSeeOther)
+}
+
+// kako bismo izlogovali korisnika, dovoljno je da samo uklonimo "authenticatedUserID" vrijednost iz sesije
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// sesija nestaje sa spiska aktivnih sesija korisnika
+	err := app.userSessions.DeleteByToken(ctx, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// za svaki slučaj osvježavamo "Session ID"
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// nakon toga, brišemo "authenticatedUserID" iz "session data", kako bismo izlogovali korisnika
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	// prikaz "flash" poruke, kako bismo obavjestili korisnika da je izlogovan
+	app.sessionManager.Put(r.Context(), "flash", "You've been successfully logged out!")
+
+	// preusmjeravanje ka "home" stranici
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// "accountView" prikazuje podatke o trenutno ulogovanom korisniku
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	// "requireAuthentication" garantuje da "authenticatedUserID" postoji u sesiji
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		// korisnik je mogao biti obrisan u međuvremenu - u tom slučaju ga šaljemo na "login" stranicu
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+
+	app.render(w, r, http.StatusOK, "account.tmpl", data)
+}
+
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+
+	app.render(w, r, http.StatusOK, "password.tmpl", data)
+}
+
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// ukoliko trenutna lozinka nije ispravna, greška se prikazuje pored odgovarajućeg polja
+	err = app.users.PasswordUpdate(ctx, userID, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldErrorKey("currentPassword", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// promjena lozinke je promjena stanja autentifikacije, pa generišemo novi Session ID
+	err = app.renewSessionToken(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// sve ostale sesije se odjavljuju - ukoliko je lozinka procurila, napadač gubi pristup
+	err = app.userSessions.RevokeAllForUser(ctx, userID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) userForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userForgotPasswordForm{}
+	app.render(w, r, http.StatusOK, "forgot.tmpl", data)
+}
+
+func (app *application) userForgotPasswordPost(w http.ResponseWriter, r *http.Request) {
+	var form userForgotPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "forgot.tmpl", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// BITNO:
+	// odgovor mora biti isti bez obzira na to da li korisnik postoji
+	// u suprotnom bi se preko ove forme moglo provjeriti koje "email" adrese imaju nalog
+	user, err := app.users.GetByEmail(ctx, form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err == nil {
+		token, err := app.tokens.New(ctx, user.ID, passwordResetTTL, models.ScopePasswordReset)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// slanje mejla može da potraje, pa ga izvršavamo u pozadini
+		app.background(func() {
+			data := map[string]any{
+				"Name":     user.Name,
+				"ResetURL": app.baseURL + "/user/password/reset?token=" + url.QueryEscape(token.Plaintext),
+				"TTL":      "1 hour",
+			}
+
+			err := app.mailer.Send(user.Email, "password_reset.tmpl", data)
+			if err != nil {
+				app.logger.Error(err.Error(), "user_id", user.ID)
+			}
+		})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account with that email exists, we've sent a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) userResetPassword(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userResetPasswordForm{
+		Token: r.URL.Query().Get("token"),
+	}
+	app.render(w, r, http.StatusOK, "reset.tmpl", data)
+}
+
+func (app *application) userResetPasswordPost(w http.ResponseWriter, r *http.Request) {
+	var form userResetPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// nova lozinka se validira na isti način kao i prilikom "sign-up"-a
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+
+	if !validator.NotBlank(form.Token) {
+		form.AddNonFieldError("This password reset link is invalid or has expired")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// token se briše zajedno sa izmjenom lozinke - ukoliko izmjena ne uspije, link i dalje važi
+	// ostali linkovi za reset, koji su eventualno poslati ranije, takođe prestaju da važe
+	userID, err := app.users.PasswordResetWithToken(ctx, form.Token, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddNonFieldError("This password reset link is invalid or has expired")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// nakon reseta lozinke, korisnik se odjavljuje sa svih uređaja
+	err = app.userSessions.RevokeAllForUser(ctx, userID, "")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// "userActivate" obrađuje link za aktivaciju iz mejla
+// link se otvara direktno iz mejl klijenta, pa ova putanja prihvata GET zahtjev
+func (app *application) userActivate(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if !validator.NotBlank(token) {
+		app.notFound(w)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID, err := app.tokens.Consume(ctx, models.ScopeActivation, token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "This verification link is invalid or has expired. You can request a new one from your account page.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.Activate(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.tokens.DeleteAllForUser(ctx, models.ScopeActivation, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified!")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// "accountActivationResendPost" šalje novi link za aktivaciju, a prethodni linkovi prestaju da važe
+func (app *application) accountActivationResendPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.Activated {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	err = app.tokens.DeleteAllForUser(ctx, models.ScopeActivation, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.sendActivationEmail(ctx, user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a new verification link to %s.", user.Email))
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// "accountTOTPEnable" prikazuje tajni ključ i "otpauth://" URI za "authenticator" aplikaciju
+// ključ se čuva u sesiji dok ga korisnik ne potvrdi ispravnim kodom - tek tada se upisuje u bazu
+func (app *application) accountTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.TOTPEnabled {
+		app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is already enabled.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	secret := app.sessionManager.GetString(r.Context(), "totpPendingSecret")
+	if secret == "" {
+		secret, err = totp.GenerateSecret()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "totpPendingSecret", secret)
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = accountTOTPEnableForm{
+		Secret: secret,
+		URI:    totp.URI("Snippetbox", user.Email, secret),
+	}
+	app.render(w, r, http.StatusOK, "totp_enable.tmpl", data)
+}
+
+func (app *application) accountTOTPEnablePost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	secret := app.sessionManager.GetString(r.Context(), "totpPendingSecret")
+	if secret == "" {
+		http.Redirect(w, r, "/account/totp/enable", http.StatusSeeOther)
+		return
+	}
+
+	var form accountTOTPEnableForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// prvi kod potvrđuje da je korisnik ispravno podesio aplikaciju
+	step, ok := totp.Validate(secret, form.Code, time.Now())
+	form.CheckField(ok, "code", "This code is incorrect")
+
+	if !form.Valid() {
+		form.Secret = secret
+		form.URI = totp.URI("Snippetbox", user.Email, secret)
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "totp_enable.tmpl", data)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	normalized := make([]string, len(codes))
+	for i, code := range codes {
+		normalized[i] = totp.NormalizeRecoveryCode(code)
+	}
+
+	err = app.users.TOTPEnable(ctx, userID, secret, normalized)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// kod koji je potvrdio uključivanje ne smije ponovo da se iskoristi za prijavu
+	_, err = app.users.TOTPUseStep(ctx, userID, step)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "totpPendingSecret")
+
+	err = app.renewSessionToken(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// kodovi za oporavak se prikazuju samo jednom, pa stranicu renderujemo direktno (bez redirekcije)
+	data := app.newTemplateData(r)
+	data.RecoveryCodes = codes
+	app.render(w, r, http.StatusOK, "totp_recovery.tmpl", data)
+}
+
+func (app *application) accountTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountTOTPDisableForm{}
+	app.render(w, r, http.StatusOK, "totp_disable.tmpl", data)
+}
+
+// isključivanje TOTP-a zahtijeva ponovni unos lozinke
+// u suprotnom bi ga mogao isključiti svako ko dođe do otključanog računara
+func (app *application) accountTOTPDisablePost(w http.ResponseWriter, r *http.Request) {
+	var form accountTOTPDisableForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "totp_disable.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.users.VerifyPassword(ctx, userID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldErrorKey("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "totp_disable.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.TOTPDisable(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.renewSessionToken(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been disabled.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// "accountSessions" prikazuje aktivne sesije korisnika
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	sessions, err := app.userSessions.ListForUser(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserSessions = sessions
+
+	// trenutnu sesiju označavamo, kako korisnik ne bi slučajno odjavio sam sebe
+	token := app.sessionManager.Token(r.Context())
+	for _, s := range sessions {
+		if s.Token == token {
+			data.CurrentSessionID = s.ID
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "sessions.tmpl", data)
+}
+
+// "accountSessionRevokePost" odjavljuje jednu sesiju (npr. sa izgubljenog laptopa)
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.userSessions.Revoke(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been logged out.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// "accountSessionsRevokeAllPost" odjavljuje sve sesije osim trenutne ("log out everywhere")
+func (app *application) accountSessionsRevokeAllPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.userSessions.RevokeAllForUser(ctx, userID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "All other sessions have been logged out.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// "accountTokens" prikazuje API tokene korisnika i formu za kreiranje novog tokena
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	data, err := app.newTokensTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Form = accountTokenCreateForm{
+		Scopes:  []string{models.APIScopeSnippetsRead},
+		Expires: 30,
+	}
+
+	app.render(w, r, http.StatusOK, "tokens.tmpl", data)
+}
+
+// "accountTokenCreatePost" kreira novi API token
+// token se prikazuje direktno u odgovoru (bez redirekcije), jer se u bazi čuva samo njegov "hash"
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountTokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Select at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.APIScopes...), "scopes", "This field contains an unknown scope")
+	}
+	form.CheckField(validator.PermittedValue(form.Expires, 7, 30, 90, 365), "expires", "This field must equal 7, 30, 90 or 365")
+
+	if !form.Valid() {
+		data, err := app.newTokensTemplateData(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "tokens.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	plaintext, err := app.apiTokens.Insert(ctx, userID, form.Name, form.Scopes, time.Duration(form.Expires)*24*time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data, err := app.newTokensTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.NewAPIToken = plaintext
+	data.Form = accountTokenCreateForm{
+		Scopes:  []string{models.APIScopeSnippetsRead},
+		Expires: 30,
+	}
+
+	// stranica sa tokenom ne smije da završi u "cache"-u browser-a
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, http.StatusOK, "tokens.tmpl", data)
+}
+
+// "accountTokenDeletePost" trajno opoziva API token
+func (app *application) accountTokenDeletePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.apiTokens.Delete(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The API token has been revoked.")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// "accountInvitations" prikazuje pozivnice korisnika i formu za kreiranje nove
+func (app *application) accountInvitations(w http.ResponseWriter, r *http.Request) {
+	data, err := app.newInvitationsTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Form = accountInvitationCreateForm{}
+	app.render(w, r, http.StatusOK, "invitations.tmpl", data)
+}
+
+// "accountInvitationCreatePost" kreira pozivnicu
+// kod se prikazuje samo jednom, a ukoliko je unesena "email" adresa - šalje se i mejlom
+func (app *application) accountInvitationCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountInvitationCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+	if form.Email != "" {
+		form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+		form.CheckField(app.signup.domainAllowed(form.Email), "email", "Signups are limited to addresses at "+strings.Join(app.signup.AllowedDomains, ", "))
+	}
+
+	data, err := app.newInvitationsTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if data.InvitationsRemaining == 0 {
+		form.AddNonFieldError("You've used all of your invitations for the last 30 days.")
+	}
+
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "invitations.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	code, err := app.invitations.Insert(ctx, userID, form.Email, app.signup.InviteTTL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.Email != "" {
+		app.sendInvitationEmail(user, form.Email, code)
+	}
+
+	data, err = app.newInvitationsTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.NewInvitationCode = code
+	data.NewInvitationURL = app.signupURL(code)
+	data.Form = accountInvitationCreateForm{}
+
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, http.StatusOK, "invitations.tmpl", data)
+}
+
+// "accountInvitationDeletePost" opoziva pozivnicu koja još nije iskorišćena
+func (app *application) accountInvitationDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readPostFormID(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.invitations.Delete(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The invitation has been revoked.")
+	http.Redirect(w, r, "/account/invitations", http.StatusSeeOther)
+}
+
+// "accountExport" vraća sve podatke korisnika u JSON formatu (nalog, "snippet"-i, sesije i API tokeni)
+// tajne vrijednosti ("hash"-evi lozinke, tokena i TOTP ključa) se namjerno ne izvoze
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippets.AllForUser(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sessions, err := app.userSessions.ListForUser(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	tokens, err := app.apiTokens.ListForUser(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	exportedSnippets := make([]map[string]any, 0, len(snippets))
+	for _, s := range snippets {
+		exportedSnippets = append(exportedSnippets, map[string]any{
+			"id":         s.ID,
+			"title":      s.Title,
+			"content":    s.Content,
+			"visibility": s.Visibility,
+			"created":    s.Created,
+			"expires":    s.Expires,
+		})
+	}
+
+	exportedSessions := make([]map[string]any, 0, len(sessions))
+	for _, s := range sessions {
+		exportedSessions = append(exportedSessions, map[string]any{
+			"created":    s.Created,
+			"last_seen":  s.LastSeen,
+			"ip":         s.IP,
+			"user_agent": s.UserAgent,
+		})
+	}
+
+	exportedTokens := make([]map[string]any, 0, len(tokens))
+	for _, t := range tokens {
+		token := map[string]any{
+			"name":    t.Name,
+			"scopes":  t.Scopes,
+			"created": t.Created,
+			"expires": t.Expires,
+		}
+		if t.LastUsed.Valid {
+			token["last_used"] = t.LastUsed.Time
+		}
+		exportedTokens = append(exportedTokens, token)
+	}
+
+	data := map[string]any{
+		"exported_at": time.Now().UTC(),
+		"account": map[string]any{
+			"id":           user.ID,
+			"name":         user.Name,
+			"email":        user.Email,
+			"created":      user.Created,
+			"activated":    user.Activated,
+			"totp_enabled": user.TOTPEnabled,
+			"role":         user.Role,
+		},
+		"snippets":   exportedSnippets,
+		"sessions":   exportedSessions,
+		"api_tokens": exportedTokens,
+	}
+
+	// "Content-Disposition" govori browser-u da odgovor sačuva kao fajl, umjesto da ga prikaže
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippetbox-export-%d.json"`, user.ID))
+	headers.Set("Cache-Control", "no-store")
+
+	err = app.writeJSON(w, http.StatusOK, data, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// "accountDelete" prikazuje formu za brisanje naloga
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{
+		Snippets: "delete",
+	}
+	app.render(w, r, http.StatusOK, "delete.tmpl", data)
+}
+
+// "accountDeletePost" trajno briše nalog nakon ponovne provjere lozinke
+// brišu se red iz "users" tabele, "snippet"-i (ili se javni anonimizuju) i sve sesije korisnika
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Snippets, "delete", "anonymize"), "snippets", "This field must equal delete or anonymize")
+	form.CheckField(form.Confirm, "confirm", "You must confirm that you want to delete your account")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.users.VerifyPassword(ctx, userID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldErrorKey("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// "snippet"-i se brišu prvi - ukoliko brisanje naloga ne uspije, korisnik može ponoviti postupak
+	err = app.snippets.DeleteForUser(ctx, userID, form.Snippets == "anonymize")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.users.Delete(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.Info("account deleted", "user_id", userID, "snippets", form.Snippets)
+
+	// trenutna sesija je već obrisana iz baze, ali je uništavamo i ovdje, kako se ne bi ponovo snimila
+	// "flash" poruka nakon toga završava u novoj, praznoj sesiji
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// "userLoginOIDC" započinje prijavu preko OpenID Connect "provider"-a
+// "state", "nonce" i PKCE "verifier" se čuvaju u sesiji i provjeravaju kada se korisnik vrati
+func (app *application) userLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.notFound(w)
+		return
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "oidcState", state)
+	app.sessionManager.Put(r.Context(), "oidcNonce", nonce)
+	app.sessionManager.Put(r.Context(), "oidcVerifier", verifier)
+	// kod pozivnice (sa "signup" stranice) je potreban za kreiranje novog naloga u "invite" režimu
+	app.sessionManager.Put(r.Context(), "oidcInvitationCode", r.URL.Query().Get("code"))
+
+	http.Redirect(w, r, app.oidc.AuthCodeURL(state, nonce, challenge), http.StatusFound)
+}
+
+// "userLoginOIDCCallback" obrađuje povratak sa stranice "provider"-a
+// nalog se povezuje preko potvrđene "email" adrese, a novi korisnik se kreira prilikom prve prijave
+func (app *application) userLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.notFound(w)
+		return
+	}
+
+	// vrijednosti iz sesije se brišu odmah, pa isti "callback" link ne može da se iskoristi dva puta
+	state := app.sessionManager.PopString(r.Context(), "oidcState")
+	nonce := app.sessionManager.PopString(r.Context(), "oidcNonce")
+	verifier := app.sessionManager.PopString(r.Context(), "oidcVerifier")
+	invitationCode := app.sessionManager.PopString(r.Context(), "oidcInvitationCode")
+
+	query := r.URL.Query()
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// korisnik je odbio prijavu ili je "provider" vratio grešku
+	if errCode := query.Get("error"); errCode != "" {
+		app.logger.Info("oidc login failed", "error", errCode, "description", query.Get("error_description"))
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Logging in with %s failed. Please try again.", app.oidcName))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	// razmjena koda ide ka "provider"-u, pa ne koristimo "queryContext" (čiji rok je namijenjen upitima nad bazom)
+	claims, err := app.oidc.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		// neispravan kod ili token nije greška na serveru, pa korisnika vraćamo na "login" stranicu
+		if errors.Is(err, oidc.ErrExchange) || errors.Is(err, oidc.ErrInvalidToken) {
+			app.logger.Warn(err.Error(), "ip", clientIP(r))
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Logging in with %s failed. Please try again.", app.oidcName))
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// nepotvrđena "email" adresa bi omogućila preuzimanje tuđeg naloga
+	if claims.Email == "" || !claims.EmailVerified {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your %s account has no verified email address.", app.oidcName))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.GetByEmail(ctx, claims.Email)
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		user, err = app.provisionOIDCUser(ctx, claims, invitationCode)
+		if err != nil {
+			if errors.Is(err, errSignupNotAllowed) {
+				app.sessionManager.Put(r.Context(), "flash", "There is no Snippetbox account for your email address, and new signups aren't allowed.")
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			} else if errors.Is(err, models.ErrInvalidInvitation) {
+				app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("There is no Snippetbox account for your email address. Signups are by invitation only - open the link from your invitation and choose \"Sign up with %s\".", app.oidcName))
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
+	if user.Disabled {
+		app.sessionManager.Put(r.Context(), "flash", "Your account has been disabled. Please contact an administrator.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	// "provider" je potvrdio "email" adresu, pa nalog možemo odmah aktivirati
+	if !user.Activated {
+		err = app.users.Activate(ctx, user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// BITNO:
+	// nalog se povezuje po "email" adresi, pa nalog sa uključenim TOTP-om i preko SSO-a mora da prođe drugi korak prijave
+	// inače bi svako ko kontroliše istu adresu kod "identity provider"-a zaobišao drugi faktor
+	if user.TOTPEnabled {
+		err = app.startTOTPLogin(r, user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+		return
+	}
+
+	err = app.logIn(r, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, app.redirectPathAfterLogin(r), http.StatusSeeOther)
 }
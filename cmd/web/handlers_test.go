@@ -0,0 +1,60 @@
+package main
+
+import (
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"testing"
+)
+
+func TestSnippetCreateFormValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		form       snippetCreateForm
+		role       string
+		wantErrors []string
+	}{
+		{
+			name: "Valid",
+			form: snippetCreateForm{Title: "O snail", Content: "Climb Mount Fuji", Expires: 7, Visibility: models.VisibilityPublic},
+		},
+		{
+			name:       "Blank and invalid fields",
+			form:       snippetCreateForm{Expires: 3, Visibility: "secret"},
+			wantErrors: []string{"title", "content", "expires", "visibility"},
+		},
+		{
+			name:       "Team visibility without team",
+			form:       snippetCreateForm{Title: "O snail", Content: "Climb Mount Fuji", Expires: 7, Visibility: models.VisibilityTeam},
+			wantErrors: []string{"visibility"},
+		},
+		{
+			name: "Team editor",
+			form: snippetCreateForm{Title: "O snail", Content: "Climb Mount Fuji", Expires: 7, Visibility: models.VisibilityTeam, TeamID: 1},
+			role: models.TeamRoleEditor,
+		},
+		{
+			name:       "Team viewer",
+			form:       snippetCreateForm{Title: "O snail", Content: "Climb Mount Fuji", Expires: 7, Visibility: models.VisibilityTeam, TeamID: 1},
+			role:       models.TeamRoleViewer,
+			wantErrors: []string{"team"},
+		},
+		{
+			name:       "Private team snippet",
+			form:       snippetCreateForm{Title: "O snail", Content: "Climb Mount Fuji", Expires: 7, Visibility: models.VisibilityPrivate, TeamID: 1},
+			role:       models.TeamRoleOwner,
+			wantErrors: []string{"visibility"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.validate(tt.role)
+
+			assert.Equal(t, len(tt.form.FieldErrors), len(tt.wantErrors))
+			for _, key := range tt.wantErrors {
+				_, ok := tt.form.FieldErrors[key]
+				assert.Equal(t, ok, true)
+			}
+		})
+	}
+}
@@ -2,12 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"slices"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/oidc"
+	"snippetbox.lazarmrkic.com/internal/totp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,14 +28,124 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request, err
 	var (
 		method = r.Method
 		uri    = r.URL.RequestURI()
-		// koristi se "debug.Stack()" da se dobije "stack trace"
-		trace = string(debug.Stack())
 	)
 
+	// upit koji nije završen na vrijeme nije "bug" u kodu, pa "stack trace" nije potreban
+	// takav slučaj logujemo na "Warn" nivou i vraćamo "503 Service Unavailable"
+	if errors.Is(err, models.ErrQueryTimeout) {
+		app.logger.Warn(err.Error(), "method", method, "uri", uri, "timeout", app.queryTimeout)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	// koristi se "debug.Stack()" da se dobije "stack trace"
+	trace := string(debug.Stack())
+
 	app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", trace)
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// "writeJSON" šalje odgovor u JSON formatu
+// podaci se prvo kodiraju u "[]byte", pa greška prilikom kodiranja ne može da ostavi djelimično poslat odgovor
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// "errorJSON" šalje grešku u obliku {"error": ...}
+// "message" može biti string ili mapa (npr. greške validacije po poljima)
+func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, status int, message any) {
+	err := app.writeJSON(w, status, map[string]any{"error": message}, nil)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// "serverErrorJSON" je JSON verzija "serverError" helper-a
+// greška se loguje na isti način, ali klijent dobija JSON umjesto "plain text" odgovora
+func (app *application) serverErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		method = r.Method
+		uri    = r.URL.RequestURI()
+	)
+
+	if errors.Is(err, models.ErrQueryTimeout) {
+		app.logger.Warn(err.Error(), "method", method, "uri", uri, "timeout", app.queryTimeout)
+		app.errorJSON(w, r, http.StatusServiceUnavailable, "the server is temporarily unable to handle the request")
+		return
+	}
+
+	app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", string(debug.Stack()))
+	app.errorJSON(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process the request")
+}
+
+// "invalidTokenResponse" se šalje kada API token ne postoji, istekao je ili je neispravnog formata
+func (app *application) invalidTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.errorJSON(w, r, http.StatusUnauthorized, "invalid or expired API token")
+}
+
+// najveća dozvoljena veličina JSON tijela zahtjeva (1 MB)
+const maxJSONBodyBytes = 1 << 20
+
+// "readJSON" dekodira JSON tijelo zahtjeva u "dst"
+// nepoznata polja, više JSON vrijednosti i preveliko tijelo se tretiraju kao greške
+// greške su opisane tako da mogu direktno da se pošalju klijentu
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
 // "clientError" šalje određeni status kod i odgovarajući opis ka korisniku
 // koristiće se u slučajevima kada postoji problem u "request"-u koji je korisnik poslao
 func (app *application) clientError(w http.ResponseWriter, status int) {
@@ -37,6 +158,375 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// "authenticatedUserID" vraća "ID" ulogovanog korisnika iz "request context"-a
+// radi i za sesije i za API tokene - ukoliko korisnik nije ulogovan, vraća "0"
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// "pagination" opisuje jednu stranicu rezultata i koristi se u "pagination" parcijalnom templejtu
+type pagination struct {
+	Page         int
+	PageSize     int
+	TotalRecords int
+}
+
+// "LastPage" je uvijek barem "1", kako bi i prazna lista imala jednu stranicu
+func (p pagination) LastPage() int {
+	if p.TotalRecords <= 0 || p.PageSize <= 0 {
+		return 1
+	}
+	return (p.TotalRecords + p.PageSize - 1) / p.PageSize
+}
+
+func (p pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+func (p pagination) HasPrevious() bool {
+	return p.Page > 1
+}
+
+func (p pagination) HasNext() bool {
+	return p.Page < p.LastPage()
+}
+
+func (p pagination) Previous() int {
+	return p.Page - 1
+}
+
+func (p pagination) Next() int {
+	return p.Page + 1
+}
+
+// "readPage" čita broj stranice iz "?page=" parametra
+// neispravna ili negativna vrijednost se tretira kao prva stranica
+func readPage(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+// "queryContext" vraća kopiju "request context"-a sa rokom za upite nad bazom
+// upit se prekida ako klijent zatvori konekciju ili ako istekne "queryTimeout"
+// BITNO:
+// pozivalac uvijek mora da pozove vraćenu "cancel" funkciju (najčešće preko "defer")
+func (app *application) queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), app.queryTimeout)
+}
+
+// "background" izvršava funkciju u novoj "goroutine"-i (npr. slanje mejla)
+// "panic" unutar nje bi srušio cijelu aplikaciju, jer ga "recoverPanic" middleware ne može uhvatiti
+// zbog toga ovdje imamo poseban "recover"
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err), "trace", string(debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// "sendActivationEmail" kreira token za aktivaciju i u pozadini šalje link korisniku
+func (app *application) sendActivationEmail(ctx context.Context, user models.User) error {
+	token, err := app.tokens.New(ctx, user.ID, activationTTL, models.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"Name":          user.Name,
+			"ActivationURL": app.baseURL + "/user/activate?token=" + url.QueryEscape(token.Plaintext),
+			"TTL":           "3 days",
+		}
+
+		err := app.mailer.Send(user.Email, "activation.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error(), "user_id", user.ID)
+		}
+	})
+
+	return nil
+}
+
+// "signupURL" vraća link za registraciju sa već popunjenim kodom pozivnice
+func (app *application) signupURL(code string) string {
+	return app.baseURL + "/user/signup?code=" + url.QueryEscape(code)
+}
+
+// "sendInvitationEmail" u pozadini šalje pozivnicu na datu adresu
+func (app *application) sendInvitationEmail(inviter models.User, email string, code string) {
+	app.background(func() {
+		data := map[string]any{
+			"InviterName": inviter.Name,
+			"SignupURL":   app.signupURL(code),
+			"TTL":         humanDays(app.signup.InviteTTL),
+		}
+
+		err := app.mailer.Send(email, "invitation.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error(), "user_id", inviter.ID)
+		}
+	})
+}
+
+// "humanDays" zaokružuje trajanje na dane, npr. "7 days" (za mejlove)
+func humanDays(d time.Duration) string {
+	days := int((d + 24*time.Hour - 1) / (24 * time.Hour))
+	if days <= 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// "domainAllowed" provjerava da li "email" adresa pripada nekoj od dozvoljenih domena
+// prazna lista dozvoljava sve domene
+func (s signupSettings) domainAllowed(email string) bool {
+	if len(s.AllowedDomains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return false
+	}
+
+	return slices.Contains(s.AllowedDomains, strings.ToLower(email[at+1:]))
+}
+
+// "logIn" završava prijavu korisnika
+func (app *application) logIn(r *http.Request, userID int) error {
+	// pozivom "RenewToken()" metode nad trenutnom sesijom se mijenja Session ID
+	// uvijek treba generisati novi Session ID kada se kod nekog korisnika stanje autentifikacije ili privilegija promijeni
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// u sesiju se dodaje ID-a trenutnog korisnika
+	// nakon toga je "ulogovan"
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+
+	// nova sesija se odmah pojavljuje na spisku sesija korisnika
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	return app.userSessions.Touch(ctx, app.sessionManager.Token(r.Context()), userID, clientIP(r), r.UserAgent())
+}
+
+// "startTOTPLogin" započinje drugi korak prijave ("/user/login/totp")
+// "authenticatedUserID" se ne upisuje u sesiju dok korisnik ne unese i kod iz "authenticator" aplikacije
+func (app *application) startTOTPLogin(r *http.Request, userID int) error {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), "totpPendingUserID", userID)
+	app.sessionManager.Remove(r.Context(), "totpAttempts")
+	return nil
+}
+
+// "renewSessionToken" generiše novi Session ID za već ulogovanog korisnika
+// zapis iz "user_sessions" tabele se prebacuje na novi token, kako sesija ne bi nestala sa spiska
+func (app *application) renewSessionToken(r *http.Request) error {
+	oldToken := app.sessionManager.Token(r.Context())
+
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	return app.userSessions.Rename(ctx, oldToken, app.sessionManager.Token(r.Context()))
+}
+
+// "redirectPathAfterLogin" vadi iz sesije putanju koju je korisnik tražio prije prijave
+// ukoliko ona ne postoji ili nije bezbjedna, korisnik se šalje na "createSnippet" stranicu
+func (app *application) redirectPathAfterLogin(r *http.Request) string {
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if !isSafeRedirectPath(path) {
+		return "/snippet/create"
+	}
+
+	return path
+}
+
+// "isSafeRedirectPath" dozvoljava samo relativne putanje unutar aplikacije
+// u suprotnom bi napadač mogao da podmetne adresu drugog sajta i iskoristi prijavu za "open redirect" napad
+// browseri tumače "//host" i "/\host" kao adresu drugog sajta, pa ih odbijamo
+func isSafeRedirectPath(path string) bool {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return false
+	}
+
+	if strings.ContainsAny(path, "\\\r\n\t") {
+		return false
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "" && u.Host == "" && u.User == nil
+}
+
+// "errSignupNotAllowed" se vraća kada je registracija zatvorena ili domen "email" adrese nije dozvoljen
+var errSignupNotAllowed = errors.New("signup not allowed")
+
+// "provisionOIDCUser" kreira nalog za korisnika koji se prvi put prijavljuje preko SSO-a
+// lozinka je nasumična i niko je ne zna - ukoliko korisnik želi i klasičnu prijavu, može da je postavi preko "forgot password" forme
+// SSO prijava poštuje ista pravila registracije kao forma, pa u "invite" režimu nalog nastaje samo uz važeću pozivnicu
+func (app *application) provisionOIDCUser(ctx context.Context, claims oidc.Claims, invitationCode string) (models.User, error) {
+	if app.signup.Mode == signupClosed || !app.signup.domainAllowed(claims.Email) {
+		return models.User{}, errSignupNotAllowed
+	}
+
+	password, err := oidc.RandomString(32)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	// pozivnica se "rezerviše" prije kreiranja naloga, isto kao u "userSignupPost"
+	var invitationID int
+	if app.signup.Mode == signupInvite {
+		if invitationCode == "" {
+			return models.User{}, models.ErrInvalidInvitation
+		}
+
+		invitationID, err = app.invitations.Redeem(ctx, invitationCode, claims.Email)
+		if err != nil {
+			return models.User{}, err
+		}
+	}
+
+	id, err := app.users.Insert(ctx, name, claims.Email, password)
+	if err != nil {
+		// nalog nije kreiran, pa pozivnica ostaje važeća
+		if invitationID != 0 {
+			releaseErr := app.invitations.Release(ctx, invitationID)
+			if releaseErr != nil {
+				return models.User{}, releaseErr
+			}
+		}
+		return models.User{}, err
+	}
+
+	if invitationID != 0 {
+		err = app.invitations.SetUsedBy(ctx, invitationID, id)
+		if err != nil {
+			return models.User{}, err
+		}
+	}
+
+	app.logger.Info("provisioned user from oidc", "user_id", id, "email", claims.Email, "subject", claims.Subject)
+
+	return models.User{ID: id, Name: name, Email: claims.Email}, nil
+}
+
+// "checkSecondFactor" provjerava TOTP kod ili, ukoliko to nije TOTP kod, jedan od kodova za oporavak
+// oba tipa koda mogu da se iskoriste samo jednom
+func (app *application) checkSecondFactor(ctx context.Context, userID int, code string) (bool, error) {
+	secret, err := app.users.TOTPSecret(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := totp.Validate(secret, code, time.Now()); ok {
+		return app.users.TOTPUseStep(ctx, userID, step)
+	}
+
+	return app.users.UseRecoveryCode(ctx, userID, totp.NormalizeRecoveryCode(code))
+}
+
+// ključevi za brojače neuspješnih prijava
+// oba "Limiter"-a mogu da dijele isti "Store", pa ključevi imaju prefiks
+func loginThrottleEmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginThrottleIPKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// "clientIP" vraća IP adresu klijenta, bez porta
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// "loginRetryAfter" vraća koliko korisnik još mora da čeka prije narednog pokušaja prijave
+// uzima se veće čekanje od ona dva (za "email" adresu i za IP adresu)
+func (app *application) loginRetryAfter(ctx context.Context, r *http.Request, email string) (time.Duration, error) {
+	now := time.Now()
+
+	byEmail, err := app.loginThrottleByEmail.RetryAfter(ctx, loginThrottleEmailKey(email), now)
+	if err != nil {
+		return 0, err
+	}
+
+	byIP, err := app.loginThrottleByIP.RetryAfter(ctx, loginThrottleIPKey(r), now)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(byEmail, byIP), nil
+}
+
+// "loginFailed" bilježi neuspješnu prijavu
+// zaključavanje naloga ili IP adrese se upisuje u log, kako bi se napadi mogli pratiti
+func (app *application) loginFailed(ctx context.Context, r *http.Request, email string) error {
+	now := time.Now()
+
+	locked, err := app.loginThrottleByEmail.Fail(ctx, loginThrottleEmailKey(email), now)
+	if err != nil {
+		return err
+	}
+	if locked {
+		app.logger.Warn("login lockout", "key", "email", "email", email, "ip", clientIP(r),
+			"until", now.Add(app.loginThrottleByEmail.LockoutDuration()))
+	}
+
+	locked, err = app.loginThrottleByIP.Fail(ctx, loginThrottleIPKey(r), now)
+	if err != nil {
+		return err
+	}
+	if locked {
+		app.logger.Warn("login lockout", "key", "ip", "email", email, "ip", clientIP(r),
+			"until", now.Add(app.loginThrottleByIP.LockoutDuration()))
+	}
+
+	return nil
+}
+
+// "loginSucceeded" vraća brojač za "email" adresu na nulu, tek nakon potpune prijave (lozinka i TOTP kod, ukoliko je uključen)
+// brojač za IP adresu ostaje, kako napadač ne bi mogao da ga resetuje prijavom na sopstveni nalog
+func (app *application) loginSucceeded(ctx context.Context, email string) error {
+	return app.loginThrottleByEmail.Reset(ctx, loginThrottleEmailKey(email))
+}
+
 // metoda koja vraća "true" ukoliko zahtjev dolazi od strane ulogovanog korisnika
 // ranije je provjeravala vrijednosti unutar "session data"
 // sada provjerava "request context"
@@ -51,6 +541,16 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
+// "isAdmin" vraća "true" ukoliko je zahtjev poslao ulogovani administrator
+func (app *application) isAdmin(r *http.Request) bool {
+	isAdmin, ok := r.Context().Value(isAdminContextKey).(bool)
+	if !ok {
+		return false
+	}
+
+	return isAdmin
+}
+
 // Create an newTemplateData() helper, which returns a pointer to a templateData
 // struct initialized with the current year. Note that we're not using the
 // *http.Request parameter here at the moment, but we will do later in the book.
@@ -61,9 +561,64 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 		Flash: app.sessionManager.PopString(r.Context(), "flash"),
 		// Add the authentication status to the template data.
 		IsAuthenticated: app.isAuthenticated(r),
+		IsAdmin:         app.isAdmin(r),
 		// dodavanje "CSRF token"-a
 		CSRFToken: nosurf.Token(r),
+		OIDCName:  app.oidcDisplayName(),
+		// "signup.tmpl" i navigacija zavise od režima registracije
+		SignupMode: app.signup.Mode,
+	}
+}
+
+func (app *application) oidcDisplayName() string {
+	if app.oidc == nil {
+		return ""
+	}
+	return app.oidcName
+}
+
+// "newInvitationsTemplateData" vraća podatke za "invitations.tmpl" stranicu
+// "InvitationsRemaining" je "-1" za administratore, koji nemaju kvotu
+func (app *application) newInvitationsTemplateData(r *http.Request) (templateData, error) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	invitations, err := app.invitations.ListForUser(ctx, userID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	data := app.newTemplateData(r)
+	data.Invitations = invitations
+	data.SignupMode = app.signup.Mode
+	data.InvitationsRemaining = -1
+
+	if !app.isAdmin(r) {
+		used, err := app.invitations.CountSince(ctx, userID, time.Now().Add(-invitationQuotaPeriod))
+		if err != nil {
+			return templateData{}, err
+		}
+		data.InvitationsRemaining = max(app.signup.InviteQuota-used, 0)
 	}
+
+	return data, nil
+}
+
+// "newTokensTemplateData" vraća podatke za "tokens.tmpl" stranicu, zajedno sa postojećim tokenima korisnika
+func (app *application) newTokensTemplateData(r *http.Request) (templateData, error) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	tokens, err := app.apiTokens.ListForUser(ctx, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		return templateData{}, err
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	return data, nil
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
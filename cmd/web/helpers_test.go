@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/oidc"
+	"strings"
+	"testing"
+)
+
+func TestIsSafeRedirectPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{name: "Empty", path: "", want: false},
+		{name: "Simple path", path: "/account/view", want: true},
+		{name: "Path with query", path: "/snippet/view/1?foo=bar", want: true},
+		{name: "Relative path", path: "account/view", want: false},
+		{name: "Absolute URL", path: "https://evil.example/", want: false},
+		{name: "Protocol-relative URL", path: "//evil.example/", want: false},
+		{name: "Backslash", path: "/\\evil.example/", want: false},
+		{name: "Newline", path: "/foo\r\nLocation: https://evil.example", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, isSafeRedirectPath(tt.path), tt.want)
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		p            pagination
+		wantLastPage int
+		wantOffset   int
+		wantPrevious bool
+		wantNext     bool
+	}{
+		{name: "Empty", p: pagination{Page: 1, PageSize: 25, TotalRecords: 0}, wantLastPage: 1, wantOffset: 0, wantPrevious: false, wantNext: false},
+		{name: "Single page", p: pagination{Page: 1, PageSize: 25, TotalRecords: 25}, wantLastPage: 1, wantOffset: 0, wantPrevious: false, wantNext: false},
+		{name: "First of many", p: pagination{Page: 1, PageSize: 25, TotalRecords: 26}, wantLastPage: 2, wantOffset: 0, wantPrevious: false, wantNext: true},
+		{name: "Middle", p: pagination{Page: 2, PageSize: 10, TotalRecords: 35}, wantLastPage: 4, wantOffset: 10, wantPrevious: true, wantNext: true},
+		{name: "Last", p: pagination{Page: 4, PageSize: 10, TotalRecords: 35}, wantLastPage: 4, wantOffset: 30, wantPrevious: true, wantNext: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.p.LastPage(), tt.wantLastPage)
+			assert.Equal(t, tt.p.Offset(), tt.wantOffset)
+			assert.Equal(t, tt.p.HasPrevious(), tt.wantPrevious)
+			assert.Equal(t, tt.p.HasNext(), tt.wantNext)
+		})
+	}
+}
+
+func TestSignupDomainAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains []string
+		email   string
+		want    bool
+	}{
+		{name: "No restriction", domains: nil, email: "alice@example.com", want: true},
+		{name: "Allowed", domains: []string{"example.com"}, email: "alice@example.com", want: true},
+		{name: "Different case", domains: []string{"example.com"}, email: "alice@Example.COM", want: true},
+		{name: "Not allowed", domains: []string{"example.com"}, email: "alice@evil.com", want: false},
+		{name: "Subdomain", domains: []string{"example.com"}, email: "alice@mail.example.com", want: false},
+		{name: "Suffix trick", domains: []string{"example.com"}, email: "alice@example.com@evil.com", want: false},
+		{name: "No at sign", domains: []string{"example.com"}, email: "example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := signupSettings{AllowedDomains: tt.domains}
+			assert.Equal(t, s.domainAllowed(tt.email), tt.want)
+		})
+	}
+}
+
+func TestProvisionOIDCUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		domains []string
+		code    string
+		wantErr error
+	}{
+		{name: "Open", mode: signupOpen},
+		{name: "Closed", mode: signupClosed, wantErr: errSignupNotAllowed},
+		{name: "Domain not allowed", mode: signupOpen, domains: []string{"example.org"}, wantErr: errSignupNotAllowed},
+		{name: "Invite with valid code", mode: signupInvite, code: "VALID"},
+		{name: "Invite without code", mode: signupInvite, wantErr: models.ErrInvalidInvitation},
+		{name: "Invite with invalid code", mode: signupInvite, code: "WRONG", wantErr: models.ErrInvalidInvitation},
+		{name: "Invite with used code", mode: signupInvite, code: "USED", wantErr: models.ErrInvalidInvitation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &stubUsers{users: map[int]models.User{}}
+			invitations := &stubInvitations{
+				codes:  map[string]int{"VALID": 1, "USED": 2},
+				used:   map[int]bool{2: true},
+				usedBy: map[int]int{},
+			}
+			app := &application{
+				logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+				signup:      signupSettings{Mode: tt.mode, AllowedDomains: tt.domains},
+				users:       users,
+				invitations: invitations,
+			}
+
+			claims := oidc.Claims{Subject: "123", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+			user, err := app.provisionOIDCUser(context.Background(), claims, tt.code)
+
+			if tt.wantErr != nil {
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+				// nalog ne smije da nastane bez dozvole
+				assert.Equal(t, len(users.users), 0)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, user.Email, "alice@example.com")
+			assert.Equal(t, users.users[user.ID].Name, "Alice")
+			if tt.mode == signupInvite {
+				assert.Equal(t, invitations.usedBy[1], user.ID)
+			}
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "Valid", body: `{"title": "O snail"}`, wantErr: ""},
+		{name: "Empty", body: ``, wantErr: "body must not be empty"},
+		{name: "Badly-formed", body: `{"title": "O snail"`, wantErr: "body contains badly-formed JSON"},
+		{name: "Wrong type", body: `{"title": 1}`, wantErr: `body contains incorrect JSON type for field "title"`},
+		{name: "Unknown key", body: `{"author": "Matsuo Bashō"}`, wantErr: `body contains unknown key "author"`},
+		{name: "Two values", body: `{"title": "a"}{"title": "b"}`, wantErr: "body must only contain a single JSON value"},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/snippets", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			var input struct {
+				Title string `json:"title"`
+			}
+			err := app.readJSON(w, r, &input)
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			assert.Equal(t, got, tt.wantErr)
+		})
+	}
+}
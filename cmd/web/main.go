@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"expvar"
 	"flag"
 	"fmt"
 	"github.com/alexedwards/scs/mysqlstore"
@@ -12,6 +14,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	// go get github.com/go-sql-driver/mysql
@@ -19,20 +22,89 @@ import (
 	// "_" je alias, moramo da ga koristimo jer ovaj paket nigdje eksplicitno ne koristimo
 	_ "github.com/go-sql-driver/mysql"
 
+	"snippetbox.lazarmrkic.com/internal/mailer"
 	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/oidc"
+	"snippetbox.lazarmrkic.com/internal/password"
+	"snippetbox.lazarmrkic.com/internal/pubsub"
+	"snippetbox.lazarmrkic.com/internal/ratelimit"
+	"snippetbox.lazarmrkic.com/internal/throttle"
+	"snippetbox.lazarmrkic.com/internal/webhooks"
 )
 
+// režimi registracije
+const (
+	signupOpen   = "open"
+	signupInvite = "invite"
+	signupClosed = "closed"
+)
+
+// "signupSettings" određuje ko može da otvori nalog
+// "AllowedDomains" važi u svim režimima - prazna lista dozvoljava sve domene
+// "InviteQuota" je broj pozivnica koje običan korisnik može da kreira u periodu od 30 dana (administratori nemaju ograničenje)
+type signupSettings struct {
+	Mode           string
+	AllowedDomains []string
+	InviteQuota    int
+	InviteTTL      time.Duration
+}
+
+// "rateLimits" sadrži posebno ograničenje za svaku grupu ruta (vidjeti "routes.go")
+type rateLimits struct {
+	dynamic   *ratelimit.Limiter
+	protected *ratelimit.Limiter
+	api       *ratelimit.Limiter
+}
+
 type application struct {
 	logger *slog.Logger
 	// dodavanje "snippets" polja u "application" struct
 	// to će omogućiti da "SnippetModel" objekat bude dostupan kontrolerima
-	snippets *models.SnippetModel
+	// tip polja je interfejs, kako bi ispred baze mogao da stoji keš
+	snippets models.SnippetModelInterface
 	// dodavanje "users" polja
-	users *models.UserModel
+	users models.UserModelInterface
+	// evidencija sesija ulogovanih korisnika (uređaj, IP adresa, posljednja aktivnost)
+	userSessions models.SessionModelInterface
+	// jednokratni tokeni (npr. za reset lozinke)
+	tokens models.TokenModelInterface
+	// lični API tokeni za skripte i alate ("Authorization: Bearer ...")
+	apiTokens models.APITokenModelInterface
+	// pozivnice za registraciju i podešavanja registracije
+	invitations models.InvitationModelInterface
+	signup      signupSettings
+	// timovi, članstva i pozivnice u tim
+	teams models.TeamModelInterface
+	// "webhook"-i, red za njihovu isporuku i podešavanja "worker"-a koji ih šalje
+	webhooks        models.WebhookModelInterface
+	webhookSender   *webhooks.Sender
+	webhookSettings webhookSettings
+	// "broker" prosljeđuje nove javne "snippet"-e otvorenim "/events" konekcijama
+	broker *pubsub.Broker
+	// izvoz i uvoz podataka (JSON Lines) za prenos između okruženja
+	transfer models.TransferModelInterface
+	// "mailer" je interfejs - mejlovi se šalju preko SMTP-a ili se upisuju u lokalni "outbox"
+	mailer mailer.Mailer
+	// javna adresa aplikacije, koja se koristi za linkove unutar mejlova
+	baseURL string
+	// zaštita od pogađanja lozinke - neuspješni pokušaji se broje po "email" adresi i po IP adresi klijenta
+	loginThrottleByEmail *throttle.Limiter
+	loginThrottleByIP    *throttle.Limiter
+	// ograničenje broja zahtjeva po klijentu (IP adresi ili korisniku)
+	rateLimits rateLimits
+	// OpenID Connect "provider" za prijavu preko kompanijskog naloga - "nil" ukoliko SSO nije podešen
+	oidc     *oidc.Provider
+	oidcName string
 	// dodavanje templateCache polja
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	// maksimalno trajanje jednog upita nad bazom, koje "handler"-i dodaju na "request context"
+	queryTimeout time.Duration
+	// rok za izvoz i uvoz podataka, koji zamjenjuje rokove servera (vidjeti "extendDeadlines")
+	transferTimeout time.Duration
+	// statistika koju administratori vide na "/debug/vars" putanji
+	metrics *expvar.Map
 }
 
 // funkcija za inicijalizovanje "connection pool"-a
@@ -56,6 +128,58 @@ func main() {
 	addr := flag.String("addr", "127.0.0.1:4000", "HTTP network address")
 	// definisanje novog "command line flag"-a, za MySQL DSN (data source name) String
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	// rok za izvršavanje upita mora biti kraći od "WriteTimeout"-a servera, kako bi korisnik dobio odgovor
+	queryTimeout := flag.Duration("query-timeout", 3*time.Second, "Maximum duration of a single database query")
+	// za izvoz i uvoz podataka ne važe "ReadTimeout" i "WriteTimeout" servera, već ovaj (duži) rok
+	transferTimeout := flag.Duration("transfer-timeout", time.Hour, "Maximum duration of an admin export or import request")
+	// podešavanja za keš ispred "SnippetModel"-a - veličina "0" isključuje keš
+	cacheSize := flag.Int("cache-size", 1000, "Maximum number of cached snippets (0 disables the cache)")
+	cacheTTL := flag.Duration("cache-ttl", 5*time.Minute, "Maximum lifetime of a cached snippet")
+	// adresa preko koje korisnici pristupaju aplikaciji - potrebna je za linkove u mejlovima
+	baseURL := flag.String("base-url", "https://localhost:4000", "Public base URL used in emailed links")
+	// podešavanja za slanje mejlova
+	// "outbox" upisuje mejlove u lokalni direktorijum, pa SMTP server nije potreban tokom razvoja
+	mailerKind := flag.String("mailer", "outbox", "Email delivery method (smtp|outbox)")
+	outboxDir := flag.String("outbox-dir", "./tmp/outbox", "Directory for emails when -mailer=outbox")
+	smtpHost := flag.String("smtp-host", "localhost", "SMTP host")
+	smtpPort := flag.Int("smtp-port", 25, "SMTP port")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpSender := flag.String("smtp-sender", "Snippetbox <no-reply@snippetbox.lazarmrkic.com>", "SMTP sender")
+	// podešavanja za SSO prijavu preko OpenID Connect "provider"-a
+	// prijava preko SSO-a je uključena samo ako je "-oidc-issuer" postavljen
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL (empty disables SSO login)")
+	oidcClientID := flag.String("oidc-client-id", "", "OpenID Connect client ID")
+	oidcClientSecret := flag.String("oidc-client-secret", "", "OpenID Connect client secret")
+	oidcRedirectURL := flag.String("oidc-redirect-url", "", "OpenID Connect redirect URL (defaults to -base-url + /user/login/oidc/callback)")
+	oidcName := flag.String("oidc-name", "SSO", "Name of the identity provider shown on the login page")
+	// podešavanja za heširanje lozinki
+	// postojeći "hash"-evi se automatski prevode na nova podešavanja prilikom sledeće prijave korisnika
+	passwordAlgorithm := flag.String("password-algorithm", password.Bcrypt, "Password hashing algorithm for new hashes (bcrypt|argon2id)")
+	bcryptCost := flag.Int("bcrypt-cost", password.DefaultBcryptCost, "bcrypt cost")
+	argon2Memory := flag.Uint("argon2-memory", uint(password.DefaultArgon2Params.Memory), "argon2id memory in KiB")
+	argon2Iterations := flag.Uint("argon2-iterations", uint(password.DefaultArgon2Params.Iterations), "argon2id iterations")
+	argon2Parallelism := flag.Uint("argon2-parallelism", uint(password.DefaultArgon2Params.Parallelism), "argon2id parallelism")
+	// podešavanja registracije
+	signupMode := flag.String("signup-mode", signupOpen, "Who can sign up (open|invite|closed)")
+	signupDomains := flag.String("signup-domains", "", "Comma-separated list of email domains allowed to sign up (empty allows all)")
+	inviteQuota := flag.Int("invite-quota", 5, "Invitations a non-admin user can create per 30 days")
+	inviteTTL := flag.Duration("invite-ttl", 7*24*time.Hour, "How long an invitation code stays valid")
+	// podešavanja za slanje "webhook"-a
+	webhookInterval := flag.Duration("webhook-interval", 5*time.Second, "How often the webhook worker checks for due deliveries")
+	webhookMaxAttempts := flag.Int("webhook-max-attempts", 8, "Delivery attempts before a webhook delivery is marked as failed")
+	webhookBatchSize := flag.Int("webhook-batch-size", 10, "Webhook deliveries sent concurrently in one pass of the worker")
+	webhookTimeout := flag.Duration("webhook-timeout", 10*time.Second, "Timeout for a single webhook request")
+	// ograničenje broja zahtjeva po klijentu, za svaku grupu ruta posebno
+	// "rate" je broj zahtjeva u sekundi (nakon što klijent potroši "burst"), a "0" isključuje ograničenje
+	dynamicRate := flag.Float64("rate-limit-dynamic", 2, "Requests per second per client on public pages (0 disables the limit)")
+	dynamicBurst := flag.Int("rate-limit-dynamic-burst", 30, "Requests a client can send at once on public pages")
+	protectedRate := flag.Float64("rate-limit-protected", 2, "Requests per second per user on pages that require login (0 disables the limit)")
+	protectedBurst := flag.Int("rate-limit-protected-burst", 30, "Requests a user can send at once on pages that require login")
+	apiRate := flag.Float64("rate-limit-api", 5, "Requests per second per user on the JSON API (0 disables the limit)")
+	apiBurst := flag.Int("rate-limit-api-burst", 50, "Requests a user can send at once on the JSON API")
+	// svaka otvorena početna stranica drži jednu "/events" konekciju
+	eventsMaxClients := flag.Int("events-max-clients", 1000, "Maximum number of open /events connections (0 means no limit)")
 	// parsiranje flag-a
 	flag.Parse()
 
@@ -63,6 +187,34 @@ func main() {
 	// ukoliko želimo da se log čuva u nekom fajlu, onda pokrećemo aplikaciju preko "go run ./cmd/web >>/tmp/web.log"
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	passwords := password.Hasher{
+		Algorithm:  *passwordAlgorithm,
+		BcryptCost: *bcryptCost,
+		Argon2: password.Argon2Params{
+			Memory:      uint32(*argon2Memory),
+			Iterations:  uint32(*argon2Iterations),
+			Parallelism: uint8(*argon2Parallelism),
+		},
+	}
+	if err := passwords.Validate(); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if *signupMode != signupOpen && *signupMode != signupInvite && *signupMode != signupClosed {
+		logger.Error(fmt.Sprintf("unknown signup mode %q", *signupMode))
+		os.Exit(1)
+	}
+
+	// domene se porede bez obzira na velika i mala slova
+	var allowedDomains []string
+	for _, domain := range strings.Split(*signupDomains, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			allowedDomains = append(allowedDomains, domain)
+		}
+	}
+
 	// inicijalizovanje "connection pool"-a
 	db, err := openDB(*dsn)
 	if err != nil {
@@ -79,6 +231,45 @@ func main() {
 		os.Exit(1)
 	}
 
+	// izbor implementacije "Mailer" interfejsa na osnovu "-mailer" flag-a
+	var mail mailer.Mailer
+	switch *mailerKind {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword, *smtpSender)
+	case "outbox":
+		mail, err = mailer.NewOutboxMailer(*outboxDir, *smtpSender)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	default:
+		logger.Error(fmt.Sprintf("unknown mailer %q", *mailerKind))
+		os.Exit(1)
+	}
+
+	// ukoliko je SSO podešen, konfiguraciju "provider"-a učitavamo odmah pri pokretanju
+	// na taj način greška u podešavanjima zaustavlja aplikaciju, umjesto da se pojavi tek prilikom prve prijave
+	var oidcProvider *oidc.Provider
+	if *oidcIssuer != "" {
+		redirectURL := *oidcRedirectURL
+		if redirectURL == "" {
+			redirectURL = strings.TrimSuffix(*baseURL, "/") + "/user/login/oidc/callback"
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		oidcProvider, err = oidc.Discover(ctx, oidc.Config{
+			Issuer:       *oidcIssuer,
+			ClientID:     *oidcClientID,
+			ClientSecret: *oidcClientSecret,
+			RedirectURL:  redirectURL,
+		})
+		cancel()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	// nova instanca "decoder"-a:
 	formDecoder := form.NewDecoder()
 
@@ -92,20 +283,96 @@ func main() {
 	// to znači da će "cookie" biti poslat iz korisničkog browsera samo prilikom korišćenja HTTPS konekcije
 	sessionManager.Cookie.Secure = true
 
+	// inicijalizovanje "models.SnippetModel" instance, koja sadrži "connection pool"
+	// ukoliko je keš uključen, model omotavamo sa "CachedSnippetModel"
+	// "metrics" se ne objavljuje globalno ("expvar.Publish"), pa ga "/debug/vars" prikazuje bez ostalih "expvar" vrijednosti
+	metrics := new(expvar.Map).Init()
+	var snippets models.SnippetModelInterface = &models.SnippetModel{DB: db}
+	if *cacheSize > 0 {
+		cached := models.NewCachedSnippetModel(snippets, *cacheSize, *cacheTTL)
+		// brojači pogodaka i promašaja su dostupni administratorima na "/debug/vars" putanji
+		metrics.Set("snippetCache", expvar.Func(func() any {
+			return cached.Stats()
+		}))
+		snippets = cached
+	}
+
+	broker := pubsub.NewBroker(*eventsMaxClients, eventsBufferSize)
+
+	// brojači neuspješnih prijava se čuvaju u memoriji, a "zaboravljaju" se nakon 24 sata
+	// IP adresa ima blaža ograničenja, jer više korisnika može dijeliti istu adresu (NAT, kancelarija...)
+	loginAttempts := throttle.NewMemoryStore(24 * time.Hour)
+	loginThrottleByEmail := throttle.New(loginAttempts, throttle.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+	})
+	loginThrottleByIP := throttle.New(loginAttempts, throttle.Policy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: time.Hour,
+	})
+
 	app := &application{
 		logger: logger,
-		// inicijalizovanje "models.SnippetModel" instance, koja sadrži "connection pool"
-		// nakon toga, dodajemo je u zavisnosti aplikacije
-		snippets: &models.SnippetModel{DB: db},
+		// nakon toga, model dodajemo u zavisnosti aplikacije
+		snippets: snippets,
 		// isti pristup i sa "users"
-		users: &models.UserModel{DB: db},
+		users:        &models.UserModel{DB: db, Passwords: passwords},
+		tokens:       &models.TokenModel{DB: db},
+		userSessions: &models.SessionModel{DB: db},
+		apiTokens:    &models.APITokenModel{DB: db},
+		invitations:  &models.InvitationModel{DB: db},
+		teams:        &models.TeamModel{DB: db},
+		webhooks:     &models.WebhookModel{DB: db},
+		transfer:     &models.TransferModel{DB: db, Passwords: passwords},
+		broker:       broker,
+		webhookSender: &webhooks.Sender{
+			Client:    webhooks.NewClient(*webhookTimeout),
+			UserAgent: "Snippetbox-Webhooks/1.0",
+		},
+		webhookSettings: webhookSettings{
+			Interval:    *webhookInterval,
+			MaxAttempts: *webhookMaxAttempts,
+			BatchSize:   *webhookBatchSize,
+			Timeout:     *webhookTimeout,
+		},
+		signup: signupSettings{
+			Mode:           *signupMode,
+			AllowedDomains: allowedDomains,
+			InviteQuota:    *inviteQuota,
+			InviteTTL:      *inviteTTL,
+		},
+		mailer: mail,
+		// "/" na kraju uklanjamo, kako bi se putanje jednostavno nadovezivale
+		baseURL:              strings.TrimSuffix(*baseURL, "/"),
+		loginThrottleByEmail: loginThrottleByEmail,
+		loginThrottleByIP:    loginThrottleByIP,
+		oidc:                 oidcProvider,
+		oidcName:             *oidcName,
+		rateLimits: rateLimits{
+			dynamic:   ratelimit.New(ratelimit.Policy{Rate: *dynamicRate, Burst: *dynamicBurst}),
+			protected: ratelimit.New(ratelimit.Policy{Rate: *protectedRate, Burst: *protectedBurst}),
+			api:       ratelimit.New(ratelimit.Policy{Rate: *apiRate, Burst: *apiBurst}),
+		},
 		// inicijalizovanje "template cache"-a
 		templateCache: templateCache,
 		// dodavanje instance "decoder"-a u "application" zavisnosti:
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		queryTimeout:   *queryTimeout,
+		metrics:        metrics,
+		// rok za izvoz i uvoz podataka
+		transferTimeout: *transferTimeout,
 	}
 
+	// "worker" za "webhook"-e radi dok god radi i server
+	go app.runWebhookWorker(context.Background())
+
 	// modifikacija za "TLS elliptic curves" - koje se koriste prilikom TLS "handshake"-a
 	// na ovaj način smanjujemo opterećenje servera
 	tlsConfig := &tls.Config{
@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
 	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -63,7 +69,14 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		// ukoliko korisnik nije ulogovan, trebamo ga preusmjeravati na "login" stranicu
 		// nakon toga, treba izaći iz "middleware" lanca
 		if !app.isAuthenticated(r) {
+			// putanju koju je korisnik tražio pamtimo u sesiji, kako bi se nakon prijave vratio na nju
+			// samo GET zahtjevi se mogu ponoviti preko redirekcije (POST forme bi izgubile podatke)
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
+
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
 		}
 
 		// BITNO:
@@ -73,6 +86,47 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// "requireActivatedUser" se nadovezuje na "requireAuthentication"
+// korisnik koji nije potvrdio "email" adresu ne može da kreira "snippet"-e
+// umjesto toga, preusmjeravamo ga na "account" stranicu, uz "flash" poruku koja objašnjava razlog
+func (app *application) requireActivatedUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+		ctx, cancel := app.queryContext(r)
+		user, err := app.users.Get(ctx, userID)
+		cancel()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !user.Activated {
+			app.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets. Check your inbox for the verification link, or request a new one below.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// "requireAdmin" se nadovezuje na "requireAuthentication"
+// uloga je već pročitana u "authenticate" middleware-u, pa ovdje nema dodatnog upita nad bazom
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		// administratorske stranice ne treba čuvati u "cache"-u browser-a
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // kako bismo izbjegli CSRF, koristićemo "nosurf" paket
 // koristi se "double-submit cookie" pristup
 // prvo se generiše "random CSRF token" i šalje korisniku unutar "CSRF cookie"-a
@@ -106,12 +160,17 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// nakon toga, provjeravamo da li korisnik sa tim "ID"-em postoji u bazi
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		// nakon toga, provjeravamo da li korisnik sa tim "ID"-em postoji u bazi i da li je nalog omogućen
+		// "Get" vraća i ulogu korisnika, pa je dovoljan jedan upit
+		// upit dobija rok iz "queryContext"-a, dok ostatak lanca nastavlja sa originalnim kontekstom
+		ctx, cancel := app.queryContext(r)
+		user, err := app.users.Get(ctx, id)
+		cancel()
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, r, err)
 			return
 		}
+		exists := err == nil && !user.Disabled
 
 		// ukoliko korisnik postoji, znamo da HTTP zahtjev dolazi od strane ulogovanog korisnika koji postoji u bazi
 		// kreiraćemo kopiju "Context" objekta, modifikovaćemo njenu vrijednost i dodjelićemo je u kopiju "request" objekta
@@ -124,6 +183,8 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		if exists {
 			// metoda "userLoginPost" će dodati ovaj ključ uz odgovarajuću vrijednost nakon uspješnog "login"-a
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+			ctx = context.WithValue(ctx, isAdminContextKey, user.IsAdmin())
 			// modifikovanje "request"-a istim pristupom kao za "Context" objekat
 			r = r.WithContext(ctx)
 		}
@@ -132,3 +193,192 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// "trackSession" se nadovezuje na "authenticate" middleware
+// za ulogovane korisnike osvježava vrijeme posljednje aktivnosti sesije (najviše jednom u minuti)
+func (app *application) trackSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.isAuthenticated(r) {
+			userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+			ctx, cancel := app.queryContext(r)
+			err := app.userSessions.Touch(ctx, app.sessionManager.Token(r.Context()), userID, clientIP(r), r.UserAgent())
+			cancel()
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// "authenticateToken" autentifikuje zahtjeve sa "Authorization: Bearer <token>" zaglavljem
+// koristi se za skripte i API, umjesto "session cookie"-ja koji postavlja "userLoginPost"
+// postavlja istu "isAuthenticatedContextKey" vrijednost kao i "authenticate", pa "requireAuthentication" radi i ovdje
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// odgovor zavisi od "Authorization" zaglavlja, pa to moramo naglasiti "cache"-ovima
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			app.invalidTokenResponse(w, r)
+			return
+		}
+
+		ctx, cancel := app.queryContext(r)
+		apiToken, err := app.apiTokens.Authenticate(ctx, strings.TrimSpace(token))
+		var user models.User
+		if err == nil {
+			// token može da nadživi korisnika (npr. obrisan ili onemogućen nalog), pa provjeravamo i njega
+			// korisnika čitamo cijelog, kako bi "requireActivatedToken" znao da li je nalog potvrđen
+			user, err = app.users.Get(ctx, apiToken.UserID)
+			if errors.Is(err, models.ErrNoRecord) || (err == nil && user.Disabled) {
+				err = models.ErrInvalidCredentials
+			}
+		}
+		cancel()
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.invalidTokenResponse(w, r)
+			} else {
+				app.serverErrorJSON(w, r, err)
+			}
+			return
+		}
+
+		reqCtx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		reqCtx = context.WithValue(reqCtx, authenticatedUserIDContextKey, apiToken.UserID)
+		reqCtx = context.WithValue(reqCtx, apiTokenContextKey, apiToken)
+		reqCtx = context.WithValue(reqCtx, isActivatedContextKey, user.Activated)
+
+		next.ServeHTTP(w, r.WithContext(reqCtx))
+	})
+}
+
+// "requireToken" je API verzija "requireAuthentication" middleware-a
+// umjesto redirekcije na "login" stranicu, vraća "401 Unauthorized" u JSON formatu
+func (app *application) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(apiTokenContextKey).(models.APIToken); !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.errorJSON(w, r, http.StatusUnauthorized, "you must provide an API token to access this resource")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// "requireActivatedToken" je API verzija "requireActivatedUser" middleware-a
+// korisnik koji nije potvrdio "email" adresu ne može da kreira (ni mijenja) "snippet"-e ni preko API tokena
+func (app *application) requireActivatedToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		activated, _ := r.Context().Value(isActivatedContextKey).(bool)
+		if !activated {
+			app.errorJSON(w, r, http.StatusForbidden, "you must verify your email address before creating or modifying snippets")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// "requireScope" vraća middleware koji propušta samo tokene sa datom dozvolom
+// primjer: api.Append(app.requireScope(models.APIScopeSnippetsWrite))
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(apiTokenContextKey).(models.APIToken)
+			if !ok || !token.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				app.errorJSON(w, r, http.StatusForbidden, fmt.Sprintf("this token does not have the %q scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// "rateLimit" vraća middleware koji ograničava broj zahtjeva po klijentu
+// ulogovani korisnici se broje po "ID"-ju (pa ih ne ograničava dijeljena IP adresa), a ostali po IP adresi
+// zato se middleware nadovezuje na "authenticate", odnosno "authenticateToken"
+// primjer: session.Append(app.rateLimit(app.rateLimits.dynamic))
+func (app *application) rateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientIP(r)
+			if app.isAuthenticated(r) {
+				key = "user:" + strconv.Itoa(app.authenticatedUserID(r))
+			}
+
+			ok, wait := limiter.Allow(key, time.Now())
+			if !ok {
+				// "Retry-After" je u cijelim sekundama, pa čekanje zaokružujemo naviše
+				w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					app.errorJSON(w, r, http.StatusTooManyRequests, "rate limit exceeded, retry later")
+				} else {
+					app.clientError(w, http.StatusTooManyRequests)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// "loadSession" učitava sesiju kao i "LoadAndSave", ali bez "buffer"-ovanja odgovora
+// "LoadAndSave" drži cijeli odgovor u memoriji dok se "handler" ne završi, što ne odgovara odgovorima koji se šalju postepeno
+// izmjene sesije (npr. "flash" poruke) se ovdje ne snimaju, pa je namijenjen samo za GET rute
+func (app *application) loadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		cookie, err := r.Cookie(app.sessionManager.Cookie.Name)
+		if err == nil {
+			token = cookie.Value
+		}
+
+		ctx, err := app.sessionManager.Load(r.Context(), token)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Vary", "Cookie")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// "extendDeadlines" zamjenjuje rokove servera ("ReadTimeout" i "WriteTimeout") dužim rokom "transferTimeout"
+// uvoz šalje veliko "multipart" tijelo, a izvoz dugačak odgovor, pa bi ih rokovi servera prekinuli na pola
+// rok ostaje ograničen, jer se ovaj "middleware" izvršava prije provjere da li je korisnik administrator
+// mora biti ispred "LoadAndSave", jer "ResponseController" ne može da dođe do konekcije kroz njegov "ResponseWriter"
+func (app *application) extendDeadlines(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Now().Add(app.transferTimeout)
+		rc := http.NewResponseController(w)
+
+		err := rc.SetReadDeadline(deadline)
+		if err == nil {
+			err = rc.SetWriteDeadline(deadline)
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	app := &application{
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		queryTimeout: time.Second,
+		users: &stubUsers{users: map[int]models.User{
+			1: {ID: 1, Activated: true},
+			2: {ID: 2, Activated: true, Disabled: true},
+		}},
+		apiTokens: &stubAPITokens{tokens: map[string]models.APIToken{
+			"sbx_active":   {ID: 1, UserID: 1},
+			"sbx_disabled": {ID: 2, UserID: 2},
+			"sbx_deleted":  {ID: 3, UserID: 3},
+		}},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "Valid token", header: "Bearer sbx_active", want: http.StatusOK},
+		{name: "No token", header: "", want: http.StatusOK},
+		{name: "Unknown token", header: "Bearer sbx_unknown", want: http.StatusUnauthorized},
+		{name: "Disabled owner", header: "Bearer sbx_disabled", want: http.StatusUnauthorized},
+		{name: "Deleted owner", header: "Bearer sbx_deleted", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/snippets", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			rr := httptest.NewRecorder()
+			app.authenticateToken(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.want)
+		})
+	}
+}
+
+func TestRequireActivatedToken(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name      string
+		activated any
+		want      int
+	}{
+		{name: "Activated", activated: true, want: http.StatusCreated},
+		{name: "Not activated", activated: false, want: http.StatusForbidden},
+		{name: "Missing", activated: nil, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/snippets", nil)
+			if tt.activated != nil {
+				r = r.WithContext(context.WithValue(r.Context(), isActivatedContextKey, tt.activated))
+			}
+
+			rr := httptest.NewRecorder()
+			app.requireActivatedToken(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.want)
+			if tt.want == http.StatusForbidden {
+				assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+				assert.Equal(t, strings.Contains(rr.Body.String(), "verify your email address"), true)
+			}
+		})
+	}
+}
+
+func TestExtendDeadlines(t *testing.T) {
+	app := &application{
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		transferTimeout: time.Second,
+	}
+
+	// "handler" čita cijelo tijelo zahtjeva, kao što to radi uvoz
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestTimeout)
+			return
+		}
+		w.Write(body)
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    int
+	}{
+		{name: "Server deadline", handler: next, want: http.StatusRequestTimeout},
+		{name: "Extended deadline", handler: app.extendDeadlines(next), want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(tt.handler)
+			ts.Config.ReadTimeout = 50 * time.Millisecond
+			ts.Start()
+			defer ts.Close()
+
+			// tijelo stiže sporije od "ReadTimeout"-a servera
+			pr, pw := io.Pipe()
+			go func() {
+				pw.Write([]byte("first "))
+				time.Sleep(100 * time.Millisecond)
+				pw.Write([]byte("second"))
+				pw.Close()
+			}()
+
+			res, err := http.Post(ts.URL, "text/plain", pr)
+			assert.NilError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, res.StatusCode, tt.want)
+		})
+	}
+}
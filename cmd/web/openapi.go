@@ -0,0 +1,406 @@
+package main
+
+import (
+	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"strings"
+)
+
+// OpenAPI 3 specifikacija JSON API-ja
+// specifikacija se gradi u kodu (a ne kao statički fajl), kako bi ograničenja i dozvoljene vrijednosti
+// dolazili iz istih konstanti koje koriste "handler"-i
+// BITNO:
+// svaka nova "/api/" ruta iz "routes()" mora biti opisana ovdje - "TestOpenAPISpecCoversRoutes" to provjerava
+
+// "openAPISpec" vraća specifikaciju, a "serverURL" je javna adresa aplikacije
+func openAPISpec(serverURL string) map[string]any {
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Snippetbox API",
+			"version":     "1.0.0",
+			"description": "JSON API for reading and managing snippets. Requests are authenticated with personal API tokens, which can be created on the account page.",
+		},
+		"servers": []any{
+			map[string]any{"url": serverURL},
+		},
+		"security": []any{
+			map[string]any{"bearerAuth": []string{}},
+		},
+		"paths": map[string]any{
+			"/api/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOpenAPISpec",
+					"summary":     "This document",
+					"tags":        []string{"meta"},
+					"security":    []any{},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The OpenAPI document.",
+							"content":     jsonContent(map[string]any{"type": "object"}),
+						},
+					},
+				},
+			},
+			"/api/v1/me": map[string]any{
+				"get": map[string]any{
+					"operationId": "getMe",
+					"summary":     "Describe the token owner and the token itself",
+					"tags":        []string{"account"},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The token owner and the token's scopes.",
+							"content":     jsonContent(schemaRef("Me")),
+						},
+						"401": responseRef("Unauthorized"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+			},
+			"/api/v1/snippets": map[string]any{
+				"get": map[string]any{
+					"operationId":      "listSnippets",
+					"summary":          "List your snippets, or a team's snippets",
+					"description":      "Returns snippets that haven't expired, newest first. Without `team` these are the token owner's own snippets (including private ones). Pages past the last one return an empty list.",
+					"tags":             []string{"snippets"},
+					"x-required-scope": models.APIScopeSnippetsRead,
+					"parameters": []any{
+						map[string]any{
+							"name":        "page",
+							"in":          "query",
+							"description": "Page number, starting at 1. Invalid values are treated as 1.",
+							"schema":      map[string]any{"type": "integer", "minimum": 1, "default": 1},
+						},
+						map[string]any{
+							"name":        "team",
+							"in":          "query",
+							"description": "List the snippets of this team instead. The token owner must be a member.",
+							"schema":      map[string]any{"type": "integer", "minimum": 1},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "A page of snippets.",
+							"content": jsonContent(map[string]any{
+								"type":     "object",
+								"required": []string{"snippets", "metadata"},
+								"properties": map[string]any{
+									"snippets": map[string]any{"type": "array", "items": schemaRef("Snippet")},
+									"metadata": schemaRef("Metadata"),
+								},
+							}),
+						},
+						"400": responseRef("BadRequest"),
+						"401": responseRef("Unauthorized"),
+						"403": responseRef("Forbidden"),
+						"404": responseRef("NotFound"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+				"post": map[string]any{
+					"operationId":      "createSnippet",
+					"summary":          "Create a snippet",
+					"description":      "Validation rules are the same as on the web form. Field errors are returned as an object keyed by field name.",
+					"tags":             []string{"snippets"},
+					"x-required-scope": models.APIScopeSnippetsWrite,
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(schemaRef("SnippetCreate")),
+					},
+					"responses": map[string]any{
+						"201": map[string]any{
+							"description": "The snippet was created.",
+							"headers": map[string]any{
+								"Location": map[string]any{
+									"description": "API URL of the new snippet.",
+									"schema":      map[string]any{"type": "string"},
+								},
+							},
+							"content": jsonContent(snippetEnvelope()),
+						},
+						"400": responseRef("BadRequest"),
+						"401": responseRef("Unauthorized"),
+						"403": responseRef("Forbidden"),
+						"422": responseRef("ValidationFailed"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+			},
+			"/api/v1/snippets/{id}": map[string]any{
+				"parameters": []any{
+					map[string]any{
+						"name":     "id",
+						"in":       "path",
+						"required": true,
+						"schema":   map[string]any{"type": "integer", "minimum": 1},
+					},
+				},
+				"get": map[string]any{
+					"operationId":      "getSnippet",
+					"summary":          "Get a snippet",
+					"description":      "Private and team snippets are only returned to their author and team members. Other callers get 404, as if the snippet didn't exist.",
+					"tags":             []string{"snippets"},
+					"x-required-scope": models.APIScopeSnippetsRead,
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The snippet.",
+							"content":     jsonContent(snippetEnvelope()),
+						},
+						"401": responseRef("Unauthorized"),
+						"403": responseRef("Forbidden"),
+						"404": responseRef("NotFound"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+				"patch": map[string]any{
+					"operationId":      "updateSnippet",
+					"summary":          "Update a snippet",
+					"description":      "Only the fields present in the body are changed. Personal snippets can be edited by their author, team snippets by the team's owners and editors. The expiry date and team can't be changed.",
+					"tags":             []string{"snippets"},
+					"x-required-scope": models.APIScopeSnippetsWrite,
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(schemaRef("SnippetUpdate")),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The updated snippet.",
+							"content":     jsonContent(snippetEnvelope()),
+						},
+						"400": responseRef("BadRequest"),
+						"401": responseRef("Unauthorized"),
+						"403": responseRef("Forbidden"),
+						"404": responseRef("NotFound"),
+						"422": responseRef("ValidationFailed"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+				"delete": map[string]any{
+					"operationId":      "deleteSnippet",
+					"summary":          "Delete a snippet",
+					"description":      "The same permissions as for updating apply.",
+					"tags":             []string{"snippets"},
+					"x-required-scope": models.APIScopeSnippetsWrite,
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The snippet was deleted.",
+							"content": jsonContent(map[string]any{
+								"type":       "object",
+								"required":   []string{"message"},
+								"properties": map[string]any{"message": map[string]any{"type": "string"}},
+							}),
+						},
+						"401": responseRef("Unauthorized"),
+						"403": responseRef("Forbidden"),
+						"404": responseRef("NotFound"),
+						"429": responseRef("TooManyRequests"),
+						"500": responseRef("ServerError"),
+						"503": responseRef("Timeout"),
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A personal API token (`sbx_...`). Each operation lists the scope it needs in `x-required-scope`; available scopes are " + quotedList(models.APIScopes) + ".",
+				},
+			},
+			"schemas": map[string]any{
+				"Snippet": map[string]any{
+					"type":     "object",
+					"required": []string{"id", "title", "content", "created", "expires", "visibility", "author", "team", "url"},
+					"properties": map[string]any{
+						"id":         map[string]any{"type": "integer"},
+						"title":      map[string]any{"type": "string", "maxLength": 100},
+						"content":    map[string]any{"type": "string"},
+						"created":    map[string]any{"type": "string", "format": "date-time"},
+						"expires":    map[string]any{"type": "string", "format": "date-time"},
+						"visibility": visibilitySchema(),
+						"author": map[string]any{
+							"allOf":    []any{schemaRef("Reference")},
+							"nullable": true,
+						},
+						"team": map[string]any{
+							"allOf":    []any{schemaRef("Reference")},
+							"nullable": true,
+						},
+						"url": map[string]any{"type": "string", "format": "uri", "description": "Web page of the snippet."},
+					},
+				},
+				"SnippetCreate": map[string]any{
+					"type":                 "object",
+					"required":             []string{"title", "content"},
+					"additionalProperties": false,
+					"properties": map[string]any{
+						"title":   map[string]any{"type": "string", "minLength": 1, "maxLength": 100},
+						"content": map[string]any{"type": "string", "minLength": 1},
+						"expires": map[string]any{
+							"type":        "integer",
+							"enum":        []int{1, 7, 365},
+							"default":     365,
+							"description": "Days until the snippet expires.",
+						},
+						"visibility": visibilitySchema(),
+						"team_id": map[string]any{
+							"type":        "integer",
+							"minimum":     0,
+							"default":     0,
+							"description": "Create the snippet under this team. The token owner must be an owner or editor of the team. Team snippets can't be private, and `team` visibility requires a team.",
+						},
+					},
+				},
+				"SnippetUpdate": map[string]any{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]any{
+						"title":   map[string]any{"type": "string", "minLength": 1, "maxLength": 100},
+						"content": map[string]any{"type": "string", "minLength": 1},
+						"visibility": map[string]any{
+							"allOf":       []any{visibilitySchema()},
+							"description": "Personal snippets can be public or private, team snippets public or team.",
+						},
+					},
+				},
+				"Reference": map[string]any{
+					"type":     "object",
+					"required": []string{"id", "name"},
+					"properties": map[string]any{
+						"id":   map[string]any{"type": "integer"},
+						"name": map[string]any{"type": "string"},
+					},
+				},
+				"Metadata": map[string]any{
+					"type":     "object",
+					"required": []string{"page", "page_size", "last_page", "total_records"},
+					"properties": map[string]any{
+						"page":          map[string]any{"type": "integer"},
+						"page_size":     map[string]any{"type": "integer"},
+						"last_page":     map[string]any{"type": "integer"},
+						"total_records": map[string]any{"type": "integer"},
+					},
+				},
+				"Me": map[string]any{
+					"type":     "object",
+					"required": []string{"user", "token"},
+					"properties": map[string]any{
+						"user": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"id":      map[string]any{"type": "integer"},
+								"name":    map[string]any{"type": "string"},
+								"email":   map[string]any{"type": "string", "format": "email"},
+								"created": map[string]any{"type": "string", "format": "date-time"},
+							},
+						},
+						"token": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"name":    map[string]any{"type": "string"},
+								"scopes":  map[string]any{"type": "array", "items": map[string]any{"type": "string", "enum": models.APIScopes}},
+								"expires": map[string]any{"type": "string", "format": "date-time"},
+							},
+						},
+					},
+				},
+				"Error": map[string]any{
+					"type":     "object",
+					"required": []string{"error"},
+					"properties": map[string]any{
+						"error": map[string]any{"type": "string"},
+					},
+				},
+				"ValidationError": map[string]any{
+					"type":     "object",
+					"required": []string{"error"},
+					"properties": map[string]any{
+						"error": map[string]any{
+							"type":                 "object",
+							"description":          "Error messages keyed by field name.",
+							"additionalProperties": map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+			"responses": map[string]any{
+				"BadRequest":       errorResponse("The body isn't valid JSON, contains unknown keys, or a query parameter is invalid."),
+				"Unauthorized":     errorResponse("The API token is missing, invalid or expired."),
+				"Forbidden":        errorResponse("The token lacks the required scope, its owner hasn't verified their email address, or isn't allowed to modify the snippet."),
+				"NotFound":         errorResponse("The resource doesn't exist or isn't visible to the token owner."),
+				"ServerError":      errorResponse("The server encountered a problem."),
+				"Timeout":          errorResponse("A database query took too long. The request can be retried."),
+				"ValidationFailed": map[string]any{"description": "One or more fields are invalid.", "content": jsonContent(schemaRef("ValidationError"))},
+				"TooManyRequests": map[string]any{
+					"description": "The token owner sent too many requests. Retry after the number of seconds in the Retry-After header.",
+					"headers": map[string]any{
+						"Retry-After": map[string]any{"schema": map[string]any{"type": "integer"}},
+					},
+					"content": jsonContent(schemaRef("Error")),
+				},
+			},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func responseRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/responses/" + name}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{"description": description, "content": jsonContent(schemaRef("Error"))}
+}
+
+func snippetEnvelope() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"required":   []string{"snippet"},
+		"properties": map[string]any{"snippet": schemaRef("Snippet")},
+	}
+}
+
+func visibilitySchema() map[string]any {
+	return map[string]any{
+		"type":    "string",
+		"enum":    []string{models.VisibilityPublic, models.VisibilityPrivate, models.VisibilityTeam},
+		"default": models.VisibilityPublic,
+	}
+}
+
+// "quotedList" pretvara listu u npr. "`a`, `b`" (za opise u specifikaciji)
+func quotedList(values []string) string {
+	return "`" + strings.Join(values, "`, `") + "`"
+}
+
+// "apiOpenAPISpec" vraća specifikaciju - ne traži token, kako bi generatori klijenata mogli da je preuzmu
+func (app *application) apiOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, openAPISpec(app.baseURL), nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// "apiDocs" prikazuje dokumentaciju API-ja
+// stranicu iscrtava "/static/js/apidocs.js" na osnovu "/api/openapi.json", pa radi i uz postojeći CSP ("default-src 'self'")
+func (app *application) apiDocs(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, http.StatusOK, "apidocs.tmpl", app.newTemplateData(r))
+}
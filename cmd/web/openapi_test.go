@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// "apiRoute" je jedna ruta iz "routes()" u OpenAPI obliku - npr. {"get", "/api/v1/snippets/{id}"}
+type apiRoute struct {
+	method string
+	path   string
+}
+
+// "registeredAPIRoutes" čita "routes.go" i vraća sve "/api/" rute registrovane preko "router.Handler" i "router.HandlerFunc"
+// ruter ne može da izlista svoje rute, pa ih čitamo direktno iz izvornog koda
+func registeredAPIRoutes(t *testing.T) []apiRoute {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	assert.NilError(t, err)
+
+	param := regexp.MustCompile(`:(\w+)`)
+
+	var routes []apiRoute
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+
+		fun, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (fun.Sel.Name != "Handler" && fun.Sel.Name != "HandlerFunc") {
+			return true
+		}
+		if x, ok := fun.X.(*ast.Ident); !ok || x.Name != "router" {
+			return true
+		}
+
+		method, ok := call.Args[0].(*ast.SelectorExpr)
+		if !ok || !strings.HasPrefix(method.Sel.Name, "Method") {
+			return true
+		}
+
+		lit, ok := call.Args[1].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		path, err := strconv.Unquote(lit.Value)
+		if err != nil || !strings.HasPrefix(path, "/api/") {
+			return true
+		}
+
+		routes = append(routes, apiRoute{
+			method: strings.ToLower(strings.TrimPrefix(method.Sel.Name, "Method")),
+			path:   param.ReplaceAllString(path, "{$1}"),
+		})
+		return true
+	})
+
+	return routes
+}
+
+// "TestOpenAPISpecCoversRoutes" pada ukoliko neka "/api/" ruta iz "routes()" nije opisana u specifikaciji
+// ili ukoliko specifikacija opisuje rutu koja ne postoji
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	routes := registeredAPIRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("no API routes found in routes.go")
+	}
+
+	paths := openAPISpec("https://localhost:4000")["paths"].(map[string]any)
+
+	registered := make(map[apiRoute]bool)
+	for _, route := range routes {
+		registered[route] = true
+
+		item, ok := paths[route.path].(map[string]any)
+		if !ok {
+			t.Errorf("%s %s is missing from the OpenAPI spec", strings.ToUpper(route.method), route.path)
+			continue
+		}
+		if _, ok := item[route.method]; !ok {
+			t.Errorf("%s %s is missing from the OpenAPI spec", strings.ToUpper(route.method), route.path)
+		}
+	}
+
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[apiRoute{method: method, path: path}] {
+				t.Errorf("%s %s is in the OpenAPI spec, but not registered in routes()", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// "TestOpenAPISpecRefs" provjerava da svaka "$ref" referenca pokazuje na postojeću komponentu
+func TestOpenAPISpecRefs(t *testing.T) {
+	js, err := json.Marshal(openAPISpec("https://localhost:4000"))
+	assert.NilError(t, err)
+
+	var spec map[string]any
+	assert.NilError(t, json.Unmarshal(js, &spec))
+
+	components := spec["components"].(map[string]any)
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				group, _ := components[parts[0]].(map[string]any)
+				if len(parts) != 2 || group[parts[1]] == nil {
+					t.Errorf("unresolved reference %q", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 	"net/http"
+	"snippetbox.lazarmrkic.com/internal/models"
 	"snippetbox.lazarmrkic.com/ui"
+	"strings"
 )
 
 // request-ovi u prvobitnoj verziji će biti proslijeđeni na sledeći šablon "secureHeaders → servemux → application handler"
@@ -26,9 +29,21 @@ func (app *application) routes() http.Handler {
 
 	// kreira se "handler" funkcija - koja omotava "notFound" helper funkciju
 	// nakon toga, postavljamo je kao "custom handler" za "404 Not Found" odgovore
+	// klijenti API-ja očekuju JSON i za greške, pa "/api/" putanje dobijaju JSON odgovor
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.notFoundJSON(w, r)
+			return
+		}
 		app.notFound(w)
 	})
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.errorJSON(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("the %s method is not supported for this resource", r.Method))
+			return
+		}
+		app.clientError(w, http.StatusMethodNotAllowed)
+	})
 
 	// kreiranje "file" servera - za fajlove iz "ui/static" foldera
 	// biće prebačeni u "http.FS" tip, a na kraju se servira "file server HTTP handler"
@@ -41,7 +56,12 @@ func (app *application) routes() http.Handler {
 	// međutim, neće biti potrebe da ga dodajemo na svaku putanju
 
 	// ubacićemo i "nosurf" middleware:
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// "trackSession" mora biti nakon "authenticate", jer zavisi od "isAuthenticated" vrijednosti u kontekstu
+	session := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate, app.trackSession)
+
+	// svaka grupa ruta ima svoje ograničenje broja zahtjeva, pa se "rateLimit" ne nalazi u zajedničkom "session" lancu
+	// "rateLimit" je nakon "authenticate", kako bi ulogovani korisnici bili brojani po "ID"-ju
+	dynamic := session.Append(app.rateLimit(app.rateLimits.dynamic))
 
 	// BITNO:
 	// "ThenFunc()" metoda vraća http.Handler (a ne "http.HandlerFunc")
@@ -49,18 +69,113 @@ func (app *application) routes() http.Handler {
 	// naredne putanje su fiksne putanje
 	// ne završavaju se sa "/"
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/user/profile/:id", dynamic.ThenFunc(app.userProfile))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/oidc", dynamic.ThenFunc(app.userLoginOIDC))
+	router.Handler(http.MethodGet, "/user/login/oidc/callback", dynamic.ThenFunc(app.userLoginOIDCCallback))
+	router.Handler(http.MethodGet, "/user/login/totp", dynamic.ThenFunc(app.userLoginTOTP))
+	router.Handler(http.MethodPost, "/user/login/totp", dynamic.ThenFunc(app.userLoginTOTPPost))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userForgotPassword))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.ThenFunc(app.userForgotPasswordPost))
+	router.Handler(http.MethodGet, "/user/password/reset", dynamic.ThenFunc(app.userResetPassword))
+	router.Handler(http.MethodPost, "/user/password/reset", dynamic.ThenFunc(app.userResetPasswordPost))
+	router.Handler(http.MethodGet, "/user/activate", dynamic.ThenFunc(app.userActivate))
+	router.Handler(http.MethodGet, "/docs/api", dynamic.ThenFunc(app.apiDocs))
+
+	// "/events" ne koristi sesiju - šalje samo javne "snippet"-e, a "LoadAndSave" bi cijeli odgovor držao u memoriji
+	// ograničenje se odnosi na nove konekcije, a ne na događaje unutar jedne konekcije
+	router.Handler(http.MethodGet, "/events", alice.New(app.rateLimit(app.rateLimits.dynamic)).ThenFunc(app.events))
 
 	// rute koje traže ulogovanog korisnika su obje rute oko kreiranja "snippet"-a i ruta za "logout"
 	// "requireAuthentication" će biti nadovezan na već postojeći "middleware" (tj. "LoadAndSave")
-	protected := dynamic.Append(app.requireAuthentication)
+	protected := session.Append(app.requireAuthentication, app.rateLimit(app.rateLimits.protected))
 
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// kreiranje "snippet"-a je dozvoljeno samo korisnicima koji su potvrdili "email" adresu
+	activated := protected.Append(app.requireActivatedUser)
+
+	router.Handler(http.MethodGet, "/snippet/create", activated.ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", activated.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", activated.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", activated.ThenFunc(app.snippetEditPost))
+	// timove kreiraju i u njih pozivaju samo korisnici sa potvrđenom "email" adresom
+	router.Handler(http.MethodGet, "/teams", activated.ThenFunc(app.teamList))
+	router.Handler(http.MethodPost, "/teams/create", activated.ThenFunc(app.teamCreatePost))
+	router.Handler(http.MethodGet, "/team/view/:id", activated.ThenFunc(app.teamView))
+	router.Handler(http.MethodPost, "/team/invite", activated.ThenFunc(app.teamInvitePost))
+	router.Handler(http.MethodPost, "/team/invitations/delete", activated.ThenFunc(app.teamInvitationDeletePost))
+	router.Handler(http.MethodPost, "/team/members/role", activated.ThenFunc(app.teamMemberRolePost))
+	router.Handler(http.MethodPost, "/team/members/remove", activated.ThenFunc(app.teamMemberRemovePost))
+	router.Handler(http.MethodGet, "/team/join", activated.ThenFunc(app.teamJoin))
+	router.Handler(http.MethodPost, "/team/join", activated.ThenFunc(app.teamJoinPost))
+	// pozivnice mogu da kreiraju samo korisnici sa potvrđenom "email" adresom
+	router.Handler(http.MethodGet, "/account/invitations", activated.ThenFunc(app.accountInvitations))
+	router.Handler(http.MethodPost, "/account/invitations/create", activated.ThenFunc(app.accountInvitationCreatePost))
+	router.Handler(http.MethodPost, "/account/invitations/delete", activated.ThenFunc(app.accountInvitationDeletePost))
+	// "webhook"-i šalju sadržaj "snippet"-a na spoljne adrese, pa su dostupni samo korisnicima sa potvrđenom "email" adresom
+	router.Handler(http.MethodGet, "/account/webhooks", activated.ThenFunc(app.accountWebhooks))
+	router.Handler(http.MethodPost, "/account/webhooks/create", activated.ThenFunc(app.accountWebhookCreatePost))
+	router.Handler(http.MethodPost, "/account/webhooks/delete", activated.ThenFunc(app.accountWebhookDeletePost))
+	router.Handler(http.MethodGet, "/account/webhooks/view/:id", activated.ThenFunc(app.accountWebhookView))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodPost, "/account/activation/resend", protected.ThenFunc(app.accountActivationResendPost))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(app.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/revoke", protected.ThenFunc(app.accountSessionRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-all", protected.ThenFunc(app.accountSessionsRevokeAllPost))
+	router.Handler(http.MethodGet, "/account/totp/enable", protected.ThenFunc(app.accountTOTPEnable))
+	router.Handler(http.MethodPost, "/account/totp/enable", protected.ThenFunc(app.accountTOTPEnablePost))
+	router.Handler(http.MethodGet, "/account/totp/disable", protected.ThenFunc(app.accountTOTPDisable))
+	router.Handler(http.MethodPost, "/account/totp/disable", protected.ThenFunc(app.accountTOTPDisablePost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens/create", protected.ThenFunc(app.accountTokenCreatePost))
+	router.Handler(http.MethodPost, "/account/tokens/delete", protected.ThenFunc(app.accountTokenDeletePost))
+	router.Handler(http.MethodGet, "/account/export", protected.ThenFunc(app.accountExport))
+	router.Handler(http.MethodGet, "/account/delete", protected.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", protected.ThenFunc(app.accountDeletePost))
+
+	// administratorske rute - "requireAdmin" se nadovezuje na "protected" lanac
+	admin := protected.Append(app.requireAdmin)
+
+	router.Handler(http.MethodGet, "/admin", admin.ThenFunc(app.adminDashboard))
+	router.Handler(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	router.Handler(http.MethodPost, "/admin/users/disable", admin.ThenFunc(app.adminUserDisablePost))
+	router.Handler(http.MethodPost, "/admin/users/enable", admin.ThenFunc(app.adminUserEnablePost))
+	router.Handler(http.MethodGet, "/admin/snippets", admin.ThenFunc(app.adminSnippets))
+	router.Handler(http.MethodPost, "/admin/snippets/delete", admin.ThenFunc(app.adminSnippetDeletePost))
+	// statistika keša je dostupna samo administratorima
+	router.Handler(http.MethodGet, "/debug/vars", admin.ThenFunc(app.adminMetrics))
+
+	// izvoz i uvoz traju duže od "ReadTimeout"-a i "WriteTimeout"-a servera, pa "extendDeadlines" ide prije ostalih "middleware"-a
+	// izvoz se šalje postepeno, pa umjesto "LoadAndSave" koristi "loadSession" - izvoz ionako ne mijenja sesiju
+	export := alice.New(app.extendDeadlines, app.loadSession, app.authenticate, app.requireAuthentication, app.rateLimit(app.rateLimits.protected), app.requireAdmin)
+	router.Handler(http.MethodGet, "/admin/export", export.ThenFunc(app.adminExport))
+	router.Handler(http.MethodPost, "/admin/import", alice.New(app.extendDeadlines).Extend(admin).ThenFunc(app.adminImportPost))
+
+	// API rute ne koriste sesije ni "noSurf" - zahtjevi se autentifikuju preko "Authorization: Bearer" tokena
+	// pošto browser ne šalje ovo zaglavlje automatski, CSRF zaštita ovdje nije potrebna
+	// zahtjevi bez ispravnog tokena se odbijaju prije ograničenja, pa se API ograničava samo po korisniku
+	api := alice.New(app.authenticateToken, app.requireToken, app.rateLimit(app.rateLimits.api))
+
+	// specifikacija je javna - ne traži ni sesiju ni token
+	router.HandlerFunc(http.MethodGet, "/api/openapi.json", app.apiOpenAPISpec)
+
+	router.Handler(http.MethodGet, "/api/v1/me", api.ThenFunc(app.apiMe))
+
+	// čitanje traži "snippets:read", a kreiranje, izmjena i brisanje "snippets:write" dozvolu
+	// kao i kod "activated" lanca, izmjene su dozvoljene samo korisnicima sa potvrđenom "email" adresom
+	apiRead := api.Append(app.requireScope(models.APIScopeSnippetsRead))
+	apiWrite := api.Append(app.requireScope(models.APIScopeSnippetsWrite), app.requireActivatedToken)
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", apiRead.ThenFunc(app.apiSnippets))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", apiRead.ThenFunc(app.apiSnippetView))
+	router.Handler(http.MethodPatch, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiSnippetDelete))
 
 	// izvršavanje svih "middleware"-a dok se ne dođe do "router"-a
 	// stara verzija - app.recoverPanic(app.logRequest(secureHeaders(mux)))
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/validator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "handler"-i za timove
+// tim ima vlasnike ("owner"), urednike ("editor") i članove koji samo čitaju ("viewer")
+// stranice tima su dostupne samo članovima - ostali dobijaju "404", kako se ne bi otkrilo da tim postoji
+
+// pozivnica u tim važi sedam dana
+const teamInvitationTTL = 7 * 24 * time.Hour
+
+// broj "snippet"-a po stranici na stranici tima
+const teamPageSize = 20
+
+type teamCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// "TeamID" se šalje kroz skriveno polje forme
+type teamInviteForm struct {
+	TeamID              int    `form:"team"`
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+// "Token" se prenosi kroz skriveno polje forme, jer ga korisnik dobija unutar linka iz mejla
+type teamJoinForm struct {
+	Token               string `form:"token"`
+	validator.Validator `form:"-"`
+}
+
+// "teamRole" vraća ulogu korisnika u timu, odnosno prazan string ukoliko korisnik nije član
+func (app *application) teamRole(ctx context.Context, teamID int, userID int) (string, error) {
+	if teamID == 0 || userID == 0 {
+		return "", nil
+	}
+
+	role, err := app.teams.Role(ctx, teamID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+// "canViewSnippet" proširuje "VisibleTo" provjerom članstva u timu
+// "snippet" vidljiv timu vide svi članovi tima, bez obzira na ulogu
+func (app *application) canViewSnippet(ctx context.Context, r *http.Request, s models.Snippet) (bool, error) {
+	userID := app.authenticatedUserID(r)
+	if s.VisibleTo(userID) {
+		return true, nil
+	}
+
+	if s.Visibility != models.VisibilityTeam {
+		return false, nil
+	}
+
+	role, err := app.teamRole(ctx, s.TeamID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return role != "", nil
+}
+
+// "canEditSnippet" provjerava da li ulogovani korisnik smije da mijenja "snippet"
+// "snippet"-e tima mijenjaju vlasnici i urednici tima (autor samo dok je i sam urednik), a lične "snippet"-e samo autor
+func (app *application) canEditSnippet(ctx context.Context, r *http.Request, s models.Snippet) (bool, error) {
+	userID := app.authenticatedUserID(r)
+	if userID == 0 {
+		return false, nil
+	}
+
+	if s.TeamID == 0 {
+		return s.UserID == userID, nil
+	}
+
+	role, err := app.teamRole(ctx, s.TeamID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return models.CanEdit(role), nil
+}
+
+// "editableTeams" vraća timove u kojima korisnik smije da kreira "snippet"-e (za "create.tmpl")
+func (app *application) editableTeams(ctx context.Context, userID int) ([]models.TeamMembership, error) {
+	teams, err := app.teams.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var editable []models.TeamMembership
+	for _, t := range teams {
+		if models.CanEdit(t.Role) {
+			editable = append(editable, t)
+		}
+	}
+
+	return editable, nil
+}
+
+// "teamList" prikazuje timove korisnika i formu za kreiranje novog tima
+func (app *application) teamList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	teams, err := app.teams.ListForUser(ctx, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Teams = teams
+	data.Form = teamCreateForm{}
+
+	app.render(w, r, http.StatusOK, "teams.tmpl", data)
+}
+
+// "teamCreatePost" kreira tim - korisnik koji ga kreira postaje njegov vlasnik
+func (app *application) teamCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form teamCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	if !form.Valid() {
+		teams, err := app.teams.ListForUser(ctx, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Teams = teams
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "teams.tmpl", data)
+		return
+	}
+
+	id, err := app.teams.Insert(ctx, form.Name, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your team has been created.")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", id), http.StatusSeeOther)
+}
+
+// "newTeamTemplateData" vraća podatke za "team.tmpl" stranicu
+// vraća "ErrNoRecord" ukoliko tim ne postoji ili ulogovani korisnik nije njegov član
+func (app *application) newTeamTemplateData(r *http.Request, teamID int) (templateData, error) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	role, err := app.teamRole(ctx, teamID, userID)
+	if err != nil {
+		return templateData{}, err
+	}
+	if role == "" {
+		return templateData{}, models.ErrNoRecord
+	}
+
+	// ulogovani korisnik je potreban za dugme "Leave team"
+	user, err := app.users.Get(ctx, userID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	team, err := app.teams.Get(ctx, teamID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	members, err := app.teams.Members(ctx, teamID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	counts, err := app.snippets.CountsForTeam(ctx, teamID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	p := pagination{Page: readPage(r), PageSize: teamPageSize, TotalRecords: counts.Active}
+	if p.Page > p.LastPage() {
+		return templateData{}, models.ErrNoRecord
+	}
+
+	snippets, err := app.snippets.ListForTeam(ctx, teamID, p.PageSize, p.Offset())
+	if err != nil {
+		return templateData{}, err
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Team = team
+	data.TeamRole = role
+	data.TeamMembers = members
+	data.Snippets = snippets
+	data.Pagination = p
+
+	// pozivnice vide samo vlasnici
+	if role == models.TeamRoleOwner {
+		data.TeamInvitations, err = app.teams.Invitations(ctx, teamID)
+		if err != nil {
+			return templateData{}, err
+		}
+	}
+
+	return data, nil
+}
+
+// "teamView" prikazuje članove i "snippet"-e tima
+func (app *application) teamView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	data, err := app.newTeamTemplateData(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data.Form = teamInviteForm{TeamID: id, Role: models.TeamRoleEditor}
+
+	app.render(w, r, http.StatusOK, "team.tmpl", data)
+}
+
+// "requireTeamOwner" provjerava da li je ulogovani korisnik vlasnik tima
+// ukoliko nije, šalje odgovarajući odgovor i vraća "false"
+func (app *application) requireTeamOwner(w http.ResponseWriter, r *http.Request, teamID int) bool {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	role, err := app.teamRole(ctx, teamID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+
+	switch role {
+	case models.TeamRoleOwner:
+		return true
+	case "":
+		app.notFound(w)
+	default:
+		app.clientError(w, http.StatusForbidden)
+	}
+	return false
+}
+
+// "readTeamMemberForm" čita "team" i "user" polja iz forme
+func (app *application) readTeamMemberForm(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	teamID, err := strconv.Atoi(r.PostForm.Get("team"))
+	if err != nil || teamID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user"))
+	if err != nil || userID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return teamID, userID, true
+}
+
+// "teamInvitePost" šalje pozivnicu u tim na datu "email" adresu
+// pozivnicu može da prihvati samo korisnik sa tom adresom (postojeći ili nakon registracije)
+func (app *application) teamInvitePost(w http.ResponseWriter, r *http.Request) {
+	var form teamInviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.TeamID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !app.requireTeamOwner(w, r, form.TeamID) {
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.PermittedValue(form.Role, models.TeamRoles...), "role", "This field must equal owner, editor or viewer")
+
+	if !form.Valid() {
+		data, err := app.newTeamTemplateData(r, form.TeamID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "team.tmpl", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	inviter, err := app.users.Get(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	team, err := app.teams.Get(ctx, form.TeamID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	token, err := app.teams.Invite(ctx, team.ID, form.Email, form.Role, userID, teamInvitationTTL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"InviterName": inviter.Name,
+			"TeamName":    team.Name,
+			"Role":        form.Role,
+			"JoinURL":     app.baseURL + "/team/join?token=" + url.QueryEscape(token),
+			"TTL":         humanDays(teamInvitationTTL),
+		}
+
+		err := app.mailer.Send(form.Email, "team_invitation.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error(), "team_id", team.ID)
+		}
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "The invitation has been sent to "+form.Email+".")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", team.ID), http.StatusSeeOther)
+}
+
+// "teamInvitationDeletePost" opoziva pozivnicu koja još nije prihvaćena
+func (app *application) teamInvitationDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readPostFormID(w, r)
+	if !ok {
+		return
+	}
+
+	teamID, err := strconv.Atoi(r.PostForm.Get("team"))
+	if err != nil || teamID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !app.requireTeamOwner(w, r, teamID) {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.teams.DeleteInvitation(ctx, teamID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The invitation has been revoked.")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+}
+
+// "teamMemberRolePost" mijenja ulogu člana tima
+func (app *application) teamMemberRolePost(w http.ResponseWriter, r *http.Request) {
+	teamID, userID, ok := app.readTeamMemberForm(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.PostForm.Get("role")
+	if !validator.PermittedValue(role, models.TeamRoles...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !app.requireTeamOwner(w, r, teamID) {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.teams.SetRole(ctx, teamID, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", "A team must have at least one owner.")
+			http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The member's role has been updated.")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+}
+
+// "teamMemberRemovePost" uklanja člana iz tima
+// vlasnik može da ukloni bilo kog člana, a ostali članovi mogu samo sami da napuste tim
+func (app *application) teamMemberRemovePost(w http.ResponseWriter, r *http.Request) {
+	teamID, userID, ok := app.readTeamMemberForm(w, r)
+	if !ok {
+		return
+	}
+
+	leaving := userID == app.authenticatedUserID(r)
+	if !leaving && !app.requireTeamOwner(w, r, teamID) {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.teams.RemoveMember(ctx, teamID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", "A team must have at least one owner. Make someone else an owner first.")
+			http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if leaving {
+		app.sessionManager.Put(r.Context(), "flash", "You have left the team.")
+		http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The member has been removed from the team.")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+}
+
+// "teamJoin" prikazuje formu za prihvatanje pozivnice
+// pozivnica se prihvata tek nakon slanja forme, kako "GET" zahtjev (npr. pregled linka) ne bi mijenjao podatke
+func (app *application) teamJoin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = teamJoinForm{Token: r.URL.Query().Get("token")}
+
+	app.render(w, r, http.StatusOK, "team_join.tmpl", data)
+}
+
+// "teamJoinPost" dodaje ulogovanog korisnika u tim
+// pozivnica važi samo za "email" adresu na koju je poslata
+func (app *application) teamJoinPost(w http.ResponseWriter, r *http.Request) {
+	var form teamJoinForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Token), "token", "This field cannot be blank")
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	var teamID int
+	if form.Valid() {
+		user, err := app.users.Get(ctx, app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		teamID, err = app.teams.AcceptInvitation(ctx, strings.TrimSpace(form.Token), user.ID, user.Email)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidTeamInvitation) {
+				form.AddNonFieldError("This invitation is invalid, has expired or was sent to a different email address.")
+			} else {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "team_join.tmpl", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Welcome to the team!")
+	http.Redirect(w, r, fmt.Sprintf("/team/view/%d", teamID), http.StatusSeeOther)
+}
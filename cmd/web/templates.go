@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
@@ -14,15 +15,53 @@ type templateData struct {
 	CurrentYear int
 	Snippet     models.Snippet
 	Snippets    []models.Snippet
+	User        models.User
 	Form        any
 	Flash       string
 	// na osnovu ovog polja će se prikazivati odgovarajući Login screen ("Authenticated" / "Not authenticated")
 	IsAuthenticated bool
+	// prikazuje link ka administratorskoj stranici i dugmad za moderaciju
+	IsAdmin bool
 	// kako bi slanje formi bilo zaštićeno od CSRF, potrebna nam je "noSurf.Token()" metoda
 	// ona uzima "CSRF token" i dodaje ga u skriveno "csrf_token" polje unutar svake naše forme
 	// na kraju ćemo morati da "štelujemo" ovaj atribut u svim HTML poljima gdje je navedena funkcionalnost potrebna
 	// prvi korak je da dodamo "CSRFToken" polje u "templateData" struct
 	CSRFToken string
+	// naziv "identity provider"-a za dugme "Log in with ..." - prazan string ukoliko SSO nije podešen
+	OIDCName string
+	// kodovi za oporavak, koji se prikazuju samo jednom - nakon uključivanja TOTP-a
+	RecoveryCodes []string
+	// aktivne sesije korisnika i "ID" sesije iz koje je stigao trenutni zahtjev
+	UserSessions     []models.UserSession
+	CurrentSessionID int
+	// API tokeni korisnika i "plain-text" vrijednost novog tokena, koja se prikazuje samo jednom
+	APITokens   []models.APIToken
+	NewAPIToken string
+	// podaci za administratorske stranice
+	Users         []models.User
+	UserCounts    models.UserCounts
+	SnippetCounts models.SnippetCounts
+	Pagination    pagination
+	// režim registracije ("open", "invite" ili "closed") i pozivnice korisnika
+	SignupMode           string
+	Invitations          []models.Invitation
+	InvitationsRemaining int
+	NewInvitationCode    string
+	NewInvitationURL     string
+	// timovi korisnika, odnosno podaci o timu i ulozi ulogovanog korisnika u njemu
+	Teams           []models.TeamMembership
+	Team            models.Team
+	TeamRole        string
+	TeamMembers     []models.TeamMember
+	TeamInvitations []models.TeamInvitation
+	// prikazuje link za izmjenu "snippet"-a
+	CanEdit bool
+	// "webhook"-i korisnika, evidencija isporuka i tajni ključ novog "webhook"-a, koji se prikazuje samo jednom
+	Webhooks         []models.Webhook
+	Webhook          models.Webhook
+	WebhookAttempts  []models.WebhookAttempt
+	WebhookEvents    []string
+	NewWebhookSecret string
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -31,7 +70,25 @@ func humanDate(t time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
-	return t.Format("02 Jan 2006 at 15:04")
+	// vrijeme se uvijek prikazuje u UTC zoni, bez obzira na zonu "time.Time" vrijednosti
+	return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+// "humanDuration" zaokružuje trajanje na sekunde ili minute, npr. "45 seconds" ili "3 minutes"
+func humanDuration(d time.Duration) string {
+	if d < time.Minute {
+		seconds := int(d.Round(time.Second).Seconds())
+		if seconds <= 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
 }
 
 // Initialize a template.FuncMap object and store it in a global variable. This is
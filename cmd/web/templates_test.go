@@ -73,3 +73,22 @@ func TestHumanDate2(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		want string
+	}{
+		{name: "Sub-second", d: 300 * time.Millisecond, want: "1 second"},
+		{name: "Seconds", d: 45 * time.Second, want: "45 seconds"},
+		{name: "One minute", d: time.Minute, want: "1 minute"},
+		{name: "Rounds up minutes", d: 14*time.Minute + time.Second, want: "15 minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, humanDuration(tt.d), tt.want)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"snippetbox.lazarmrkic.com/internal/models"
+)
+
+// "stub" modeli čuvaju podatke u memoriji
+// metode koje testovi ne koriste ostaju neimplementirane (ugrađeni "nil" interfejs)
+type stubUsers struct {
+	models.UserModelInterface
+	users map[int]models.User
+}
+
+func (m *stubUsers) Get(ctx context.Context, id int) (models.User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return models.User{}, models.ErrNoRecord
+	}
+	return user, nil
+}
+
+func (m *stubUsers) Insert(ctx context.Context, name string, email string, password string) (int, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return 0, models.ErrDuplicateEmail
+		}
+	}
+
+	id := len(m.users) + 1
+	m.users[id] = models.User{ID: id, Name: name, Email: email}
+	return id, nil
+}
+
+type stubAPITokens struct {
+	models.APITokenModelInterface
+	tokens map[string]models.APIToken
+}
+
+func (m *stubAPITokens) Authenticate(ctx context.Context, plaintext string) (models.APIToken, error) {
+	token, ok := m.tokens[plaintext]
+	if !ok {
+		return models.APIToken{}, models.ErrInvalidCredentials
+	}
+	return token, nil
+}
+
+// "stubInvitations" prihvata samo kodove iz "codes" ("code" -> "ID" pozivnice)
+type stubInvitations struct {
+	models.InvitationModelInterface
+	codes  map[string]int
+	used   map[int]bool
+	usedBy map[int]int
+}
+
+func (m *stubInvitations) Redeem(ctx context.Context, plaintext string, email string) (int, error) {
+	id, ok := m.codes[plaintext]
+	if !ok || m.used[id] {
+		return 0, models.ErrInvalidInvitation
+	}
+	m.used[id] = true
+	return id, nil
+}
+
+func (m *stubInvitations) Release(ctx context.Context, id int) error {
+	m.used[id] = false
+	return nil
+}
+
+func (m *stubInvitations) SetUsedBy(ctx context.Context, id int, userID int) error {
+	m.usedBy[id] = userID
+	return nil
+}
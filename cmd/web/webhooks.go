@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"snippetbox.lazarmrkic.com/internal/validator"
+	"snippetbox.lazarmrkic.com/internal/webhooks"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// "webhook"-i šalju JSON događaje ("snippet.created", "snippet.edited", "snippet.expired") na URL-ove korisnika
+// "webhook" pripada ili korisniku (lični "snippet"-i) ili timu ("snippet"-i tima) - timske "webhook"-e uređuju samo vlasnici tima
+// događaji se ne šalju direktno iz "handler"-a, već se upisuju u red u bazi, a "worker" ih šalje i ponavlja neuspješne pokušaje
+
+// broj pokušaja po stranici na stranici sa evidencijom isporuka
+const webhookLogPageSize = 50
+
+// čekanje prije prvog ponovnog pokušaja - svako sledeće čekanje je duplo duže, ali ne duže od "webhookMaxBackoff"
+const (
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+// "webhookSettings" određuje rad "worker"-a
+// "BatchSize" je broj isporuka koje se šalju istovremeno u jednom prolazu
+type webhookSettings struct {
+	Interval    time.Duration
+	MaxAttempts int
+	BatchSize   int
+	Timeout     time.Duration
+}
+
+// "Events" se popunjava iz više "checkbox" polja sa istim imenom
+// "TeamID" je "0" za lični "webhook"
+type webhookCreateForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"events"`
+	TeamID              int      `form:"team"`
+	validator.Validator `form:"-"`
+}
+
+// "HasEvent" se koristi u templejtu, kako bi izabrani događaji ostali označeni nakon greške u validaciji
+func (f webhookCreateForm) HasEvent(event string) bool {
+	return slices.Contains(f.Events, event)
+}
+
+// "validate" provjerava formu - "teams" su timovi čiji je korisnik vlasnik
+func (f *webhookCreateForm) validate(teams []models.TeamMembership) {
+	f.URL = strings.TrimSpace(f.URL)
+	f.CheckField(validator.NotBlank(f.URL), "url", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	if validator.NotBlank(f.URL) {
+		u, err := url.Parse(f.URL)
+		f.CheckField(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "This field must be a valid http or https URL")
+	}
+
+	f.CheckField(len(f.Events) > 0, "events", "Select at least one event")
+	for _, event := range f.Events {
+		f.CheckField(validator.PermittedValue(event, models.WebhookEvents...), "events", "This field contains an unknown event")
+	}
+
+	if f.TeamID != 0 {
+		owner := slices.ContainsFunc(teams, func(t models.TeamMembership) bool {
+			return t.ID == f.TeamID
+		})
+		f.CheckField(owner, "team", "You must be an owner of this team")
+	}
+}
+
+// "ownedTeams" vraća timove čiji je korisnik vlasnik - samo za njih može da registruje "webhook"
+func (app *application) ownedTeams(ctx context.Context, userID int) ([]models.TeamMembership, error) {
+	teams, err := app.teams.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var owned []models.TeamMembership
+	for _, t := range teams {
+		if t.Role == models.TeamRoleOwner {
+			owned = append(owned, t)
+		}
+	}
+
+	return owned, nil
+}
+
+// "canManageWebhook" provjerava da li korisnik smije da vidi i briše "webhook"
+func (app *application) canManageWebhook(ctx context.Context, userID int, hook models.Webhook) (bool, error) {
+	if hook.TeamID == 0 {
+		return hook.UserID == userID, nil
+	}
+
+	role, err := app.teamRole(ctx, hook.TeamID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return role == models.TeamRoleOwner, nil
+}
+
+func (app *application) newWebhooksTemplateData(r *http.Request) (templateData, error) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	hooks, err := app.webhooks.ListForUser(ctx, userID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	teams, err := app.ownedTeams(ctx, userID)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = hooks
+	data.Teams = teams
+	data.WebhookEvents = models.WebhookEvents
+
+	return data, nil
+}
+
+// "accountWebhooks" prikazuje "webhook"-e korisnika i formu za registrovanje novog
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	data, err := app.newWebhooksTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Form = webhookCreateForm{Events: models.WebhookEvents}
+
+	app.render(w, r, http.StatusOK, "webhooks.tmpl", data)
+}
+
+// "accountWebhookCreatePost" registruje novi "webhook"
+// tajni ključ se prikazuje direktno u odgovoru (bez redirekcije), isto kao i novi API token
+func (app *application) accountWebhookCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form webhookCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	userID := app.authenticatedUserID(r)
+
+	teams, err := app.ownedTeams(ctx, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate(teams)
+
+	if !form.Valid() {
+		data, err := app.newWebhooksTemplateData(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "webhooks.tmpl", data)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// timski "webhook" ne pripada korisniku koji ga je kreirao - ostaje i kada korisnik napusti tim
+	ownerID := userID
+	if form.TeamID != 0 {
+		ownerID = 0
+	}
+
+	_, err = app.webhooks.Insert(ctx, ownerID, form.TeamID, form.URL, secret, form.Events)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data, err := app.newWebhooksTemplateData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.NewWebhookSecret = secret
+	data.Form = webhookCreateForm{Events: models.WebhookEvents}
+
+	// stranica sa tajnim ključem ne smije da završi u "cache"-u browser-a
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, http.StatusOK, "webhooks.tmpl", data)
+}
+
+// "accountWebhookDeletePost" briše "webhook" zajedno sa evidencijom isporuka
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	_, err = app.readManagedWebhook(ctx, r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.webhooks.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The webhook has been deleted.")
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// "readManagedWebhook" vraća "ErrNoRecord" i kada "webhook" postoji, ali ga korisnik ne smije vidjeti
+func (app *application) readManagedWebhook(ctx context.Context, r *http.Request, id int) (models.Webhook, error) {
+	hook, err := app.webhooks.Get(ctx, id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	ok, err := app.canManageWebhook(ctx, app.authenticatedUserID(r), hook)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if !ok {
+		return models.Webhook{}, models.ErrNoRecord
+	}
+
+	return hook, nil
+}
+
+// "accountWebhookView" prikazuje evidenciju pokušaja isporuke, od najnovijeg ka najstarijem
+func (app *application) accountWebhookView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	hook, err := app.readManagedWebhook(ctx, r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	count, err := app.webhooks.CountAttempts(ctx, hook.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	p := pagination{Page: readPage(r), PageSize: webhookLogPageSize, TotalRecords: count}
+	if p.Page > p.LastPage() {
+		app.notFound(w)
+		return
+	}
+
+	attempts, err := app.webhooks.Attempts(ctx, hook.ID, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = hook
+	data.WebhookAttempts = attempts
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "webhook.tmpl", data)
+}
+
+// "dispatchWebhookEvent" u pozadini upisuje događaj u red za sve "webhook"-e pretplaćene na njega
+// "snippet" se ponovo učitava iz baze, kako bi "payload" imao iste podatke kao i API odgovor
+func (app *application) dispatchWebhookEvent(event string, snippetID int) {
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), app.queryTimeout)
+		defer cancel()
+
+		s, err := app.snippets.Get(ctx, snippetID)
+		if err != nil {
+			app.logger.Error(err.Error(), "event", event, "snippet", snippetID)
+			return
+		}
+
+		err = app.enqueueWebhookEvent(ctx, event, s)
+		if err != nil {
+			app.logger.Error(err.Error(), "event", event, "snippet", snippetID)
+		}
+	})
+}
+
+// "enqueueWebhookEvent" upisuje događaj u red
+// lični "webhook"-i dobijaju događaje za lične "snippet"-e autora, a timski za "snippet"-e tima
+func (app *application) enqueueWebhookEvent(ctx context.Context, event string, s models.Snippet) error {
+	userID := s.UserID
+	if s.TeamID != 0 {
+		userID = 0
+	}
+
+	hooks, err := app.webhooks.Subscribers(ctx, event, userID, s.TeamID)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"event":   event,
+		"created": time.Now().UTC(),
+		"snippet": app.snippetJSON(s),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		err = app.webhooks.Enqueue(ctx, hook.ID, event, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// "runWebhookWorker" šalje isporuke iz reda dok se aplikacija ne ugasi
+func (app *application) runWebhookWorker(ctx context.Context) {
+	ticker := time.NewTicker(app.webhookSettings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.processWebhooks(ctx)
+		}
+	}
+}
+
+// "processWebhooks" je jedan prolaz "worker"-a
+// "panic" u jednom prolazu se loguje, a "worker" nastavlja sa radom u sledećem
+func (app *application) processWebhooks(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%s", err), "trace", string(debug.Stack()))
+		}
+	}()
+
+	settings := app.webhookSettings
+
+	// istekli "snippet"-i se ne brišu odmah iz baze, pa "worker" sam pronalazi one za koje događaj još nije poslat
+	queryCtx, cancel := context.WithTimeout(ctx, app.queryTimeout)
+	expired, err := app.webhooks.TakeExpiredSnippets(queryCtx, settings.BatchSize)
+	cancel()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, s := range expired {
+		queryCtx, cancel := context.WithTimeout(ctx, app.queryTimeout)
+		err = app.enqueueWebhookEvent(queryCtx, models.EventSnippetExpired, s)
+		cancel()
+		if err != nil {
+			app.logger.Error(err.Error(), "event", models.EventSnippetExpired, "snippet", s.ID)
+		}
+	}
+
+	// "lease" mora biti duži od slanja i upisivanja rezultata, kako isporuka ne bi bila poslata dva puta
+	queryCtx, cancel = context.WithTimeout(ctx, app.queryTimeout)
+	deliveries, err := app.webhooks.ClaimDue(queryCtx, settings.BatchSize, settings.Timeout+time.Minute)
+	cancel()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d models.WebhookDelivery) {
+			defer wg.Done()
+			app.deliverWebhook(ctx, d)
+		}(d)
+	}
+	wg.Wait()
+}
+
+// "deliverWebhook" šalje jednu isporuku i upisuje rezultat pokušaja
+func (app *application) deliverWebhook(ctx context.Context, d models.WebhookDelivery) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%s", err), "trace", string(debug.Stack()))
+		}
+	}()
+
+	attemptedAt := time.Now()
+	result := app.webhookSender.Send(ctx, d.URL, d.Secret, d.Event, d.ID, d.Payload)
+
+	attempt := models.WebhookAttempt{
+		AttemptedAt:    attemptedAt,
+		ResponseStatus: result.Status,
+		Duration:       result.Duration,
+	}
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+	}
+
+	attempts := d.Attempts + 1
+	status := models.DeliveryPending
+	nextAttempt := attemptedAt.Add(webhooks.Backoff(attempts, webhookBaseBackoff, webhookMaxBackoff))
+	switch {
+	case result.OK():
+		status = models.DeliverySucceeded
+	case attempts >= app.webhookSettings.MaxAttempts:
+		status = models.DeliveryFailed
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, app.queryTimeout)
+	defer cancel()
+
+	err := app.webhooks.RecordAttempt(queryCtx, d.ID, attempt, status, nextAttempt)
+	if err != nil {
+		app.logger.Error(err.Error(), "delivery", d.ID)
+		return
+	}
+
+	if status == models.DeliveryFailed {
+		app.logger.Warn("webhook delivery failed", "delivery", d.ID, "webhook", d.WebhookID, "attempts", attempts)
+	}
+}
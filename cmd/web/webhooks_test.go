@@ -0,0 +1,63 @@
+package main
+
+import (
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"testing"
+)
+
+func TestWebhookCreateFormValidate(t *testing.T) {
+	owned := []models.TeamMembership{{Team: models.Team{ID: 1, Name: "Haiku"}, Role: models.TeamRoleOwner}}
+
+	tests := []struct {
+		name       string
+		form       webhookCreateForm
+		wantErrors []string
+	}{
+		{
+			name: "Valid",
+			form: webhookCreateForm{URL: "https://example.com/hooks", Events: []string{models.EventSnippetCreated}},
+		},
+		{
+			name: "Valid team webhook",
+			form: webhookCreateForm{URL: " http://localhost:8080/hooks ", Events: models.WebhookEvents, TeamID: 1},
+		},
+		{
+			name:       "Blank fields",
+			form:       webhookCreateForm{},
+			wantErrors: []string{"url", "events"},
+		},
+		{
+			name:       "Unsupported scheme",
+			form:       webhookCreateForm{URL: "ftp://example.com/hooks", Events: []string{models.EventSnippetCreated}},
+			wantErrors: []string{"url"},
+		},
+		{
+			name:       "Missing host",
+			form:       webhookCreateForm{URL: "https:///hooks", Events: []string{models.EventSnippetCreated}},
+			wantErrors: []string{"url"},
+		},
+		{
+			name:       "Unknown event",
+			form:       webhookCreateForm{URL: "https://example.com/hooks", Events: []string{"snippet.deleted"}},
+			wantErrors: []string{"events"},
+		},
+		{
+			name:       "Team not owned",
+			form:       webhookCreateForm{URL: "https://example.com/hooks", Events: []string{models.EventSnippetCreated}, TeamID: 2},
+			wantErrors: []string{"team"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.validate(owned)
+
+			assert.Equal(t, len(tt.form.FieldErrors), len(tt.wantErrors))
+			for _, key := range tt.wantErrors {
+				_, ok := tt.form.FieldErrors[key]
+				assert.Equal(t, ok, true)
+			}
+		})
+	}
+}
@@ -20,3 +20,13 @@ func Equal[T comparable](t *testing.T, actual, expected T) {
 		t.Errorf("got: %v; want: %v", actual, expected)
 	}
 }
+
+// "NilError" prekida test ukoliko greška nije "nil"
+// koristi se za korake koji moraju uspjeti kako bi ostatak testa imao smisla
+func NilError(t *testing.T, actual error) {
+	t.Helper()
+
+	if actual != nil {
+		t.Fatalf("got: %v; expected: nil", actual)
+	}
+}
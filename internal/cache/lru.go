@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// "LRU" je "in-process" keš sa ograničenim brojem unosa
+// kada se kapacitet popuni, izbacuje se unos koji je najduže vremena nekorišćen (least recently used)
+// svaki unos pored toga ima i rok trajanja, nakon kog se smatra nevažećim
+// sve metode su bezbjedne za istovremeno korišćenje iz više "goroutine"-a
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[K]*list.Element
+	stats    Stats
+	// "now" postoji kako bi testovi mogli da kontrolišu vrijeme
+	now func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// "Stats" sadrži brojače koji služe za podešavanje veličine i trajanja keša
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// "New" kreira keš sa datim kapacitetom
+// "ttl" predstavlja maksimalno trajanje jednog unosa
+func New[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// "Get" vraća vrijednost za dati ključ, ukoliko ona postoji i nije istekla
+// svaki pogodak pomjera unos na početak liste
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		if c.now().Before(e.expires) {
+			c.ll.MoveToFront(el)
+			c.stats.Hits++
+			return e.value, true
+		}
+		// istekli unos odmah uklanjamo, kako ne bi zauzimao mjesto
+		c.removeElement(el)
+	}
+
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+// "Set" dodaje ili zamjenjuje unos
+// ukoliko je "expires" raniji od "now + ttl", unos važi samo do "expires"
+// na taj način keš nikad ne vraća podatke koji su već istekli u bazi
+func (c *LRU[K, V]) Set(key K, value V, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline := c.now().Add(c.ttl)
+	if !expires.IsZero() && expires.Before(deadline) {
+		deadline = expires
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expires = deadline
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expires: deadline})
+
+	if c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// "Delete" uklanja unos iz keša (ukoliko on postoji)
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// "Purge" uklanja sve unose, ali zadržava brojače
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	clear(c.items)
+}
+
+// "Stats" vraća kopiju trenutnih brojača
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Size = c.ll.Len()
+	return s
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2023, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	newCache := func() *LRU[int, string] {
+		c := New[int, string](2, time.Minute)
+		c.now = func() time.Time { return now }
+		return c
+	}
+
+	t.Run("Hit and miss", func(t *testing.T) {
+		c := newCache()
+		c.Set(1, "one", time.Time{})
+
+		v, ok := c.Get(1)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, v, "one")
+
+		_, ok = c.Get(2)
+		assert.Equal(t, ok, false)
+
+		stats := c.Stats()
+		assert.Equal(t, stats.Hits, uint64(1))
+		assert.Equal(t, stats.Misses, uint64(1))
+		assert.Equal(t, stats.Size, 1)
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		c := newCache()
+		c.Set(1, "one", time.Time{})
+		c.Set(2, "two", time.Time{})
+		c.Get(1)
+		c.Set(3, "three", time.Time{})
+
+		_, ok := c.Get(2)
+		assert.Equal(t, ok, false)
+		_, ok = c.Get(1)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, c.Stats().Evictions, uint64(1))
+	})
+
+	t.Run("Respects TTL", func(t *testing.T) {
+		c := newCache()
+		c.Set(1, "one", time.Time{})
+
+		c.now = func() time.Time { return now.Add(time.Minute) }
+		_, ok := c.Get(1)
+		assert.Equal(t, ok, false)
+		assert.Equal(t, c.Stats().Size, 0)
+	})
+
+	t.Run("Respects earlier expiry", func(t *testing.T) {
+		c := newCache()
+		c.Set(1, "one", now.Add(time.Second))
+
+		c.now = func() time.Time { return now.Add(time.Second) }
+		_, ok := c.Get(1)
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newCache()
+		c.Set(1, "one", time.Time{})
+		c.Delete(1)
+
+		_, ok := c.Get(1)
+		assert.Equal(t, ok, false)
+	})
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	tt "text/template"
+	"time"
+)
+
+// šabloni za mejlove se "ugrađuju" u program, na isti način kao i HTML templejti u "ui" paketu
+// svaki šablon mora da definiše "subject", "plainBody" i "htmlBody" templejte
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// "Mailer" interfejs omogućava da aplikacija šalje mejlove bez obzira na to kako se oni isporučuju
+// u produkciji se koristi SMTP, a lokalno (i u testovima) mejlovi se upisuju u "outbox" direktorijum
+type Mailer interface {
+	Send(recipient string, templateFile string, data any) error
+}
+
+// "message" je mejl nakon renderovanja šablona, spreman za isporuku
+type message struct {
+	From    string
+	To      string
+	Subject string
+	Date    time.Time
+	Plain   string
+	HTML    string
+}
+
+// "render" izvršava tri templejta iz datog šablona
+// "subject" i "plainBody" se izvršavaju preko "text/template" (bez HTML "escape"-ovanja), a "htmlBody" preko "html/template"
+func render(sender, recipient, templateFile string, data any) (*message, error) {
+	textTmpl, err := tt.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	if err = textTmpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	if err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data); err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	if err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data); err != nil {
+		return nil, err
+	}
+
+	return &message{
+		From:    sender,
+		To:      recipient,
+		Subject: strings.TrimSpace(subject.String()),
+		Date:    time.Now(),
+		Plain:   plainBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
+
+// "Bytes" vraća mejl u "multipart/alternative" formatu (RFC 5322)
+// klijent sam bira da li će prikazati "plain text" ili HTML verziju
+func (m *message) Bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	header := new(bytes.Buffer)
+	fmt.Fprintf(header, "From: %s\r\n", m.From)
+	fmt.Fprintf(header, "To: %s\r\n", m.To)
+	fmt.Fprintf(header, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(header, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
+	fmt.Fprintf(header, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(header, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", m.Plain},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = pw.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(header.Bytes(), buf.Bytes()...), nil
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// "OutboxMailer" ne šalje mejlove, nego ih upisuje kao ".eml" fajlove u dati direktorijum
+// koristi se tokom lokalnog razvoja i u testovima, kada SMTP server nije dostupan
+// ".eml" fajlovi se mogu otvoriti u bilo kom mejl klijentu
+type OutboxMailer struct {
+	dir    string
+	sender string
+	seq    atomic.Uint64
+}
+
+func NewOutboxMailer(dir string, sender string) (*OutboxMailer, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMailer{dir: dir, sender: sender}, nil
+}
+
+func (m *OutboxMailer) Send(recipient string, templateFile string, data any) error {
+	msg, err := render(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	// naziv fajla sadrži vrijeme i redni broj, kako se mejlovi poslati u istoj sekundi ne bi preklopili
+	name := fmt.Sprintf("%s-%04d.eml", msg.Date.UTC().Format("20060102T150405"), m.seq.Add(1))
+
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o640)
+}
+
+// provjera da oba tipa implementiraju "Mailer" interfejs
+var (
+	_ Mailer = (*SMTPMailer)(nil)
+	_ Mailer = (*OutboxMailer)(nil)
+)
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := NewOutboxMailer(dir, "Snippetbox <no-reply@snippetbox.test>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]any{
+		"Name":     "Alice",
+		"ResetURL": "https://snippetbox.test/user/password/reset?token=abc",
+		"TTL":      "1 hour",
+	}
+
+	err = m.Send("alice@example.com", "password_reset.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(files), 1)
+
+	body, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"To: alice@example.com",
+		"Subject: Reset your Snippetbox password",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		`<a href="https://snippetbox.test/user/password/reset?token=abc">`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("message does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// "SMTPMailer" šalje mejlove preko SMTP servera
+// ukoliko server podržava STARTTLS, "net/smtp" ga automatski koristi
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+func NewSMTPMailer(host string, port int, username, password, sender string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		sender: sender,
+	}
+
+	// autentifikacija nije obavezna - lokalni "relay" serveri je često ne traže
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+func (m *SMTPMailer) Send(recipient string, templateFile string, data any) error {
+	msg, err := render(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.sender, []string{recipient}, body)
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tabela za lične API tokene ("personal access tokens"):
+//
+//	CREATE TABLE api_tokens (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    user_id INTEGER NOT NULL,
+//	    name VARCHAR(100) NOT NULL,
+//	    token_hash BINARY(32) NOT NULL,
+//	    scopes VARCHAR(255) NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    expires DATETIME NOT NULL,
+//	    last_used DATETIME NULL,
+//	    CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash),
+//	    CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//	);
+
+// dozvole koje API token može da ima
+const (
+	APIScopeSnippetsRead  = "snippets:read"
+	APIScopeSnippetsWrite = "snippets:write"
+)
+
+// "APIScopes" sadrži sve dozvole, redom kojim se prikazuju na formi
+var APIScopes = []string{APIScopeSnippetsRead, APIScopeSnippetsWrite}
+
+// prefiks olakšava prepoznavanje tokena (npr. alatima koji traže tajne u "commit"-ovima)
+const apiTokenPrefix = "sbx_"
+
+// "APIToken" ne sadrži "plain-text" vrijednost - ona se vraća samo prilikom kreiranja
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed sql.NullTime
+}
+
+// "HasScope" vraća "true" ukoliko token ima datu dozvolu
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type APITokenModelInterface interface {
+	Insert(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (string, error)
+	ListForUser(ctx context.Context, userID int) ([]APIToken, error)
+	Delete(ctx context.Context, userID int, id int) error
+	DeleteAllForUser(ctx context.Context, userID int) error
+	Authenticate(ctx context.Context, plaintext string) (APIToken, error)
+}
+
+type APITokenModel struct {
+	DB *sql.DB
+}
+
+// "Insert" kreira novi token i vraća njegovu "plain-text" vrijednost
+// u bazi se čuva samo SHA-256 "hash", pa se token može prikazati korisniku samo jednom
+func (m *APITokenModel) Insert(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (string, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := apiTokenPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+	hash := sha256.Sum256([]byte(plaintext))
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created, expires)
+    VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, name, hash[:], strings.Join(scopes, " "), time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", wrapTimeout(err)
+	}
+
+	return plaintext, nil
+}
+
+func (m *APITokenModel) ListForUser(ctx context.Context, userID int) ([]APIToken, error) {
+	stmt := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+    WHERE user_id = ? ORDER BY created DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+
+		t.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return tokens, nil
+}
+
+// "Delete" briše token - uslov "user_id = ?" sprječava brisanje tuđih tokena
+func (m *APITokenModel) Delete(ctx context.Context, userID int, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE user_id = ? AND id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "DeleteAllForUser" briše sve tokene korisnika (npr. kada administrator onemogući nalog)
+// ponovnim omogućavanjem naloga tokeni se ne vraćaju - korisnik mora da kreira nove
+func (m *APITokenModel) DeleteAllForUser(ctx context.Context, userID int) error {
+	stmt := `DELETE FROM api_tokens WHERE user_id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID)
+	return wrapTimeout(err)
+}
+
+// "Authenticate" vraća token koji odgovara datoj "plain-text" vrijednosti
+// ukoliko token ne postoji ili je istekao, vraća se "ErrInvalidCredentials"
+func (m *APITokenModel) Authenticate(ctx context.Context, plaintext string) (APIToken, error) {
+	if !strings.HasPrefix(plaintext, apiTokenPrefix) {
+		return APIToken{}, ErrInvalidCredentials
+	}
+
+	hash := sha256.Sum256([]byte(plaintext))
+
+	var t APIToken
+	var scopes string
+
+	stmt := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP()`
+
+	err := m.DB.QueryRowContext(ctx, stmt, hash[:]).Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, ErrInvalidCredentials
+		} else {
+			return APIToken{}, wrapTimeout(err)
+		}
+	}
+	t.Scopes = strings.Fields(scopes)
+
+	// "last_used" se mijenja najviše jednom u minuti, kako skripte ne bi pravile upis u bazu na svaki zahtjev
+	stmt = `UPDATE api_tokens SET last_used = UTC_TIMESTAMP()
+    WHERE id = ? AND (last_used IS NULL OR last_used < UTC_TIMESTAMP() - INTERVAL 1 MINUTE)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, t.ID)
+	if err != nil {
+		return APIToken{}, wrapTimeout(err)
+	}
+
+	return t, nil
+}
@@ -1,9 +1,28 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"fmt"
 )
 
 var ErrNoRecord = errors.New("models: no matching record found")
 var ErrInvalidCredentials = errors.New("models: invalid credentials")
 var ErrDuplicateEmail = errors.New("models: duplicate email")
+
+// lozinka je ispravna, ali je administrator onemogućio nalog
+var ErrAccountDisabled = errors.New("models: account disabled")
+
+// ova greška se vraća kada upit nad bazom ne završi prije isteka roka iz "context"-a
+// "handler"-i je mogu prepoznati preko "errors.Is()" i drugačije je logovati
+var ErrQueryTimeout = errors.New("models: query timed out")
+
+// ukoliko je greška nastala zbog isteka roka unutar "context"-a, omotavamo je sa "ErrQueryTimeout"
+// originalna greška ostaje dostupna unutar lanca, pa i dalje važi "errors.Is(err, context.DeadlineExceeded)"
+func wrapTimeout(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+
+	return err
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tabela za pozivnice, koje su potrebne za registraciju kada je uključen "invite-only" režim:
+//
+//	CREATE TABLE invitations (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    code_hash BINARY(32) NOT NULL,
+//	    created_by INTEGER NOT NULL,
+//	    email VARCHAR(255) NULL,
+//	    created DATETIME NOT NULL,
+//	    expires DATETIME NOT NULL,
+//	    used_at DATETIME NULL,
+//	    used_by INTEGER NULL,
+//	    CONSTRAINT invitations_uc_code_hash UNIQUE (code_hash),
+//	    CONSTRAINT invitations_fk_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE,
+//	    CONSTRAINT invitations_fk_used_by FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL
+//	);
+
+// "ErrInvalidInvitation" se vraća kada pozivnica ne postoji, istekla je, već je iskorišćena
+// ili je vezana za neku drugu "email" adresu
+var ErrInvalidInvitation = errors.New("models: invalid invitation")
+
+// "Invitation" ne sadrži "plain-text" kod - on se prikazuje samo prilikom kreiranja
+// "Email" je prazan string ukoliko pozivnicu može da iskoristi bilo ko
+type Invitation struct {
+	ID        int
+	CreatedBy int
+	Email     string
+	Created   time.Time
+	Expires   time.Time
+	UsedAt    sql.NullTime
+	UsedBy    int
+}
+
+// "Status" se koristi u templejtima
+func (i Invitation) Status() string {
+	switch {
+	case i.UsedAt.Valid:
+		return "used"
+	case time.Now().After(i.Expires):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+type InvitationModelInterface interface {
+	Insert(ctx context.Context, createdBy int, email string, ttl time.Duration) (string, error)
+	ListForUser(ctx context.Context, userID int) ([]Invitation, error)
+	CountSince(ctx context.Context, userID int, since time.Time) (int, error)
+	Delete(ctx context.Context, userID int, id int) error
+	Redeem(ctx context.Context, plaintext string, email string) (int, error)
+	Release(ctx context.Context, id int) error
+	SetUsedBy(ctx context.Context, id int, userID int) error
+}
+
+type InvitationModel struct {
+	DB *sql.DB
+}
+
+// "Insert" kreira pozivnicu i vraća njen "plain-text" kod
+func (m *InvitationModel) Insert(ctx context.Context, createdBy int, email string, ttl time.Duration) (string, error) {
+	// kod se generiše na isti način kao i jednokratni tokeni
+	token, err := generateToken(createdBy, ttl, "")
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO invitations (code_hash, created_by, email, created, expires)
+    VALUES (?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, token.Hash, createdBy, sql.NullString{String: email, Valid: email != ""}, token.Expiry.UTC())
+	if err != nil {
+		return "", wrapTimeout(err)
+	}
+
+	return token.Plaintext, nil
+}
+
+func (m *InvitationModel) ListForUser(ctx context.Context, userID int) ([]Invitation, error) {
+	stmt := `SELECT id, created_by, email, created, expires, used_at, used_by FROM invitations
+    WHERE created_by = ? ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		var i Invitation
+		var email sql.NullString
+		var usedBy sql.NullInt64
+
+		err = rows.Scan(&i.ID, &i.CreatedBy, &email, &i.Created, &i.Expires, &i.UsedAt, &usedBy)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+
+		i.Email = email.String
+		i.UsedBy = int(usedBy.Int64)
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return invitations, nil
+}
+
+// "CountSince" vraća broj pozivnica koje je korisnik kreirao od datog trenutka (za kvote)
+// broje se i iskorišćene i opozvane pozivnice, kako se kvota ne bi mogla zaobići
+func (m *InvitationModel) CountSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int
+
+	stmt := `SELECT COUNT(*) FROM invitations WHERE created_by = ? AND created >= ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, since.UTC()).Scan(&count)
+	return count, wrapTimeout(err)
+}
+
+// "Delete" opoziva pozivnicu koja još nije iskorišćena
+func (m *InvitationModel) Delete(ctx context.Context, userID int, id int) error {
+	stmt := `DELETE FROM invitations WHERE created_by = ? AND id = ? AND used_at IS NULL`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "Redeem" označava pozivnicu kao iskorišćenu i vraća njen "ID"
+// "UPDATE" ponovo provjerava "used_at IS NULL", pa dva istovremena zahtjeva ne mogu iskoristiti isti kod
+// ukoliko registracija nakon toga ne uspije, pozivnicu treba vratiti preko "Release"
+func (m *InvitationModel) Redeem(ctx context.Context, plaintext string, email string) (int, error) {
+	hash := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(plaintext))))
+
+	var id int
+
+	stmt := `SELECT id FROM invitations WHERE code_hash = ? AND used_at IS NULL AND expires > UTC_TIMESTAMP()
+    AND (email IS NULL OR email = ?)`
+
+	err := m.DB.QueryRowContext(ctx, stmt, hash[:], email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidInvitation
+		}
+		return 0, wrapTimeout(err)
+	}
+
+	stmt = `UPDATE invitations SET used_at = UTC_TIMESTAMP() WHERE id = ? AND used_at IS NULL`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	// ukoliko je neko drugi u međuvremenu iskoristio pozivnicu, "UPDATE" ne mijenja nijedan red
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, ErrInvalidInvitation
+	}
+
+	return id, nil
+}
+
+// "Release" vraća pozivnicu u stanje prije "Redeem" poziva
+func (m *InvitationModel) Release(ctx context.Context, id int) error {
+	stmt := `UPDATE invitations SET used_at = NULL, used_by = NULL WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return wrapTimeout(err)
+}
+
+// "SetUsedBy" bilježi korisnika koji je iskoristio pozivnicu
+func (m *InvitationModel) SetUsedBy(ctx context.Context, id int, userID int) error {
+	stmt := `UPDATE invitations SET used_by = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, id)
+	return wrapTimeout(err)
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// "scs/mysqlstore" čuva podatke sesije kao "gob" zapis, pa iz "sessions" tabele ne možemo saznati kom korisniku sesija pripada
+// zbog toga za svaku sesiju ulogovanog korisnika vodimo poseban zapis, vezan za isti token:
+//
+//	CREATE TABLE user_sessions (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    token CHAR(43) NOT NULL,
+//	    user_id INTEGER NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    last_seen DATETIME NOT NULL,
+//	    ip VARCHAR(45) NOT NULL,
+//	    user_agent VARCHAR(255) NOT NULL,
+//	    CONSTRAINT user_sessions_uc_token UNIQUE (token),
+//	    CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//	);
+type UserSession struct {
+	ID        int
+	Token     string
+	UserID    int
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+}
+
+type SessionModelInterface interface {
+	Touch(ctx context.Context, token string, userID int, ip string, userAgent string) error
+	Rename(ctx context.Context, oldToken string, newToken string) error
+	ListForUser(ctx context.Context, userID int) ([]UserSession, error)
+	Revoke(ctx context.Context, userID int, id int) error
+	RevokeAllForUser(ctx context.Context, userID int, exceptToken string) error
+	DeleteByToken(ctx context.Context, token string) error
+	RevokeAll(ctx context.Context) (int, error)
+	CountActive(ctx context.Context) (int, error)
+}
+
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// "Touch" bilježi da je sesija aktivna
+// ukoliko zapis ne postoji (npr. sesija je nastala prije uvođenja ove tabele), kreira se novi
+// "last_seen" se mijenja najviše jednom u minuti, kako ne bismo imali upis u bazu na svaki zahtjev
+func (m *SessionModel) Touch(ctx context.Context, token string, userID int, ip string, userAgent string) error {
+	stmt := `INSERT INTO user_sessions (token, user_id, created, last_seen, ip, user_agent)
+    VALUES (?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?)
+    ON DUPLICATE KEY UPDATE
+        ip = IF(last_seen < UTC_TIMESTAMP() - INTERVAL 1 MINUTE, VALUES(ip), ip),
+        user_agent = IF(last_seen < UTC_TIMESTAMP() - INTERVAL 1 MINUTE, VALUES(user_agent), user_agent),
+        last_seen = IF(last_seen < UTC_TIMESTAMP() - INTERVAL 1 MINUTE, VALUES(last_seen), last_seen)`
+
+	// "user_agent" kolona ima ograničenu dužinu
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	_, err := m.DB.ExecContext(ctx, stmt, token, userID, ip, userAgent)
+	return wrapTimeout(err)
+}
+
+// "Rename" prebacuje zapis na novi token, nakon "RenewToken()" poziva
+func (m *SessionModel) Rename(ctx context.Context, oldToken string, newToken string) error {
+	stmt := `UPDATE user_sessions SET token = ? WHERE token = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, newToken, oldToken)
+	return wrapTimeout(err)
+}
+
+// "ListForUser" vraća aktivne sesije korisnika, od posljednje korišćene
+// zapisi čija je sesija istekla (ili obrisana) iz "sessions" tabele se ne prikazuju
+func (m *SessionModel) ListForUser(ctx context.Context, userID int) ([]UserSession, error) {
+	stmt := `SELECT us.id, us.token, us.user_id, us.created, us.last_seen, us.ip, us.user_agent
+    FROM user_sessions us
+    JOIN sessions s ON s.token = us.token
+    WHERE us.user_id = ? AND s.expiry > UTC_TIMESTAMP(6)
+    ORDER BY us.last_seen DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+	for rows.Next() {
+		var s UserSession
+		err = rows.Scan(&s.ID, &s.Token, &s.UserID, &s.Created, &s.LastSeen, &s.IP, &s.UserAgent)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return sessions, nil
+}
+
+// "Revoke" briše jednu sesiju korisnika - i naš zapis i sam zapis iz "sessions" tabele
+// nakon toga, browser sa tim "cookie"-jem više nije ulogovan
+// uslov "user_id = ?" sprječava da korisnik obriše tuđu sesiju
+func (m *SessionModel) Revoke(ctx context.Context, userID int, id int) error {
+	stmt := `DELETE us, s FROM user_sessions us
+    LEFT JOIN sessions s ON s.token = us.token
+    WHERE us.user_id = ? AND us.id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "RevokeAllForUser" briše sve sesije korisnika, osim sesije sa tokenom "exceptToken"
+// ukoliko je "exceptToken" prazan string, brišu se sve sesije ("log out everywhere")
+func (m *SessionModel) RevokeAllForUser(ctx context.Context, userID int, exceptToken string) error {
+	stmt := `DELETE us, s FROM user_sessions us
+    LEFT JOIN sessions s ON s.token = us.token
+    WHERE us.user_id = ? AND us.token <> ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, exceptToken)
+	return wrapTimeout(err)
+}
+
+// "DeleteByToken" briše naš zapis za sesiju (npr. prilikom "logout"-a)
+func (m *SessionModel) DeleteByToken(ctx context.Context, token string) error {
+	stmt := `DELETE FROM user_sessions WHERE token = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, token)
+	return wrapTimeout(err)
+}
+
+// "RevokeAll" odjavljuje sve korisnike - briše sve sesije iz "sessions" tabele i naše zapise o njima
+// vraća broj obrisanih sesija
+func (m *SessionModel) RevokeAll(ctx context.Context) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sessions`)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_sessions`)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return int(rows), wrapTimeout(tx.Commit())
+}
+
+// "CountActive" vraća broj sesija koje još nisu istekle
+// "expiry" kolonu upisuje "mysqlstore", sa preciznošću od mikrosekunde
+func (m *SessionModel) CountActive(ctx context.Context) (int, error) {
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expiry > UTC_TIMESTAMP(6)`).Scan(&count)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return count, nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -13,13 +14,109 @@ import (
 // "models" paket predstavlja "service" / "data access" layer
 // u ovom paketu ćemo enkapsulirati kod za rad sa MySQL-om
 
+// vidljivost "snippet"-a
+// javni "snippet"-i se prikazuju na početnoj stranici i na profilu autora, a privatne vidi samo autor
+// "snippet"-e vidljive timu vide autor i svi članovi tima (i "viewer"-i)
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+	VisibilityTeam    = "team"
+)
+
 // polja Snippet "struct"-a će da odgovaraju poljima MySQL tabele
+// "UserID" je "0" za "snippet"-e koji su kreirani prije uvođenja autora
+// "AuthorName" se ne čuva u "snippets" tabeli, već se čita iz "users" tabele
+// "TeamID" je "0" za lične "snippet"-e, a "TeamName" se čita iz "teams" tabele (DDL je u "teams.go")
+//
+//	ALTER TABLE snippets ADD user_id INTEGER NULL;
+//	ALTER TABLE snippets ADD visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+//	ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+//	CREATE INDEX idx_snippets_user_id ON snippets(user_id, expires);
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	Title      string
+	Content    string
+	Created    time.Time
+	Expires    time.Time
+	UserID     int
+	AuthorName string
+	Visibility string
+	TeamID     int
+	TeamName   string
+}
+
+// "IsPublic" se koristi i u templejtima
+func (s Snippet) IsPublic() bool {
+	return s.Visibility == VisibilityPublic
+}
+
+// "VisibleTo" provjerava da li korisnik sa datim "ID"-em smije da vidi "snippet"
+// "userID" je "0" za anonimne posjetioce
+// članstvo u timu se ovdje ne provjerava - za "snippet"-e tima "handler" dodatno provjerava ulogu korisnika
+func (s Snippet) VisibleTo(userID int) bool {
+	return s.IsPublic() || (userID != 0 && s.UserID == userID)
+}
+
+// interfejs koji opisuje metode "SnippetModel"-a
+// "handler"-i zavise od interfejsa, pa ispred baze možemo da postavimo keš (ili neku drugu implementaciju)
+type SnippetModelInterface interface {
+	Get(ctx context.Context, id int) (Snippet, error)
+	Latest(ctx context.Context) ([]Snippet, error)
+	Insert(ctx context.Context, userID int, teamID int, title string, content string, expires int, visibility string) (int, error)
+	Update(ctx context.Context, id int, title string, content string, visibility string) error
+	List(ctx context.Context, limit int, offset int) ([]Snippet, error)
+	Counts(ctx context.Context) (SnippetCounts, error)
+	Delete(ctx context.Context, id int) error
+	ListForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error)
+	CountsForUser(ctx context.Context, userID int) (SnippetCounts, error)
+	ListActiveForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error)
+	AllForUser(ctx context.Context, userID int) ([]Snippet, error)
+	DeleteForUser(ctx context.Context, userID int, anonymizePublic bool) error
+	ListForTeam(ctx context.Context, teamID int, limit int, offset int) ([]Snippet, error)
+	CountsForTeam(ctx context.Context, teamID int) (SnippetCounts, error)
+	DeleteExpired(ctx context.Context, olderThan time.Duration, includeUnnotified bool) (int, int, error)
+}
+
+// "SnippetCounts" sadrži osnovnu statistiku o "snippet"-ima (za administratorsku stranicu i profile korisnika)
+// "Public" je broj javnih "snippet"-a koji još nisu istekli
+type SnippetCounts struct {
+	Total   int
+	Active  int
+	Expired int
+	Public  int
+}
+
+// kolone koje čitaju svi upiti nad "snippets" tabelom, redom kojim ih očekuje "scanSnippet"
+// "LEFT JOIN" je potreban jer "snippet" ne mora imati autora, niti pripadati timu
+const snippetColumns = `s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.visibility, s.team_id, t.name
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id`
+
+// "rowScanner" pokriva i "*sql.Row" i "*sql.Rows"
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnippet(row rowScanner, s *Snippet) error {
+	var userID sql.NullInt64
+	var authorName sql.NullString
+	var teamID sql.NullInt64
+	var teamName sql.NullString
+
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &userID, &authorName, &s.Visibility, &teamID, &teamName)
+	if err != nil {
+		return err
+	}
+
+	s.UserID = int(userID.Int64)
+	s.AuthorName = authorName.String
+	s.TeamID = int(teamID.Int64)
+	s.TeamName = teamName.String
+	return nil
+}
+
+// "nullableID" pretvara "0" u SQL "NULL" vrijednost
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
 }
 
 // deklarisanjem ovog tipa i implementiranjem metoda nad njim - imamo jedan enkapsulirani objekat
@@ -28,23 +125,27 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+// sve metode primaju "context.Context" kao prvi parametar
+// na taj način se upit prekida čim klijent zatvori konekciju ili istekne rok koji je "handler" postavio
+func (m *SnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
 
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
-	row := m.DB.QueryRow(stmt, id)
+	// "Get" vraća i privatne "snippet"-e - "handler" je dužan da provjeri vidljivost preko "VisibleTo"
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`
+	row := m.DB.QueryRowContext(ctx, stmt, id)
 
 	var s Snippet
 
 	// metodu "row.Scan()" koristimo da kopiramo vrijednosti iz "sql.Row" polja u odgovarajuće polje u "Snippet" struct-u
 	// parametri ove metode su "pointer"-i ka mjestima gdje želimo da iskopiramo vrijednosti
 	// broj argumenata mora biti isti kao i broj kolona koje naredba vraća
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// ovdje se to radi kroz "scanSnippet" helper, jer više upita čita iste kolone
+	err := scanSnippet(row, &s)
 	if err != nil {
 		// ukoliko "query" ne vraća nijedan red, onda će se vratiti "custom" greška - koju smo definisali u "models/errors.go"
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
 		} else {
-			return Snippet{}, err
+			return Snippet{}, wrapTimeout(err)
 		}
 	}
 
@@ -52,30 +153,30 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 }
 
 // skraćena verzija ove metode iznad:
-func (m *SnippetModel) GetShorthand(id int) (Snippet, error) {
+func (m *SnippetModel) GetShorthand(ctx context.Context, id int) (Snippet, error) {
 	var s Snippet
 
-	err := m.DB.QueryRow(`SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`, id).
-		Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := scanSnippet(m.DB.QueryRowContext(ctx, `SELECT `+snippetColumns+` WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`, id), &s)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
 		} else {
-			return Snippet{}, err
+			return Snippet{}, wrapTimeout(err)
 		}
 	}
 
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+func (m *SnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	// na početnoj stranici se prikazuju samo javni "snippet"-i
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expires > UTC_TIMESTAMP() AND s.visibility = 'public' ORDER BY s.id DESC LIMIT 10`
 
 	// "Query" metoda će vratiti više redova odjednom
 	// odnosno, vratiće "sql.Rows" resultset
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, stmt)
 	if err != nil {
-		return nil, err
+		return nil, wrapTimeout(err)
 	}
 
 	// "sql.Rows" resultset treba da bude zatvoren
@@ -84,10 +185,10 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	var snippets []Snippet
 	for rows.Next() {
 		var s Snippet
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = scanSnippet(rows, &s)
 
 		if err != nil {
-			return nil, err
+			return nil, wrapTimeout(err)
 		}
 
 		// dodavanje popunjenih "Snippet" instanci u "snippets" slice
@@ -97,21 +198,22 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// nakon završetka "rows.Next()" petlje, provjeravamo da li su se desile greške tokom iteracije
 	// ovo je veoma bitan korak, jer nekad radimo sa ogromnim resultset-ovima
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, wrapTimeout(err)
 	}
 
 	return snippets, nil
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// "userID" je "0" za "snippet"-e bez autora, a "teamID" je "0" za lične "snippet"-e
+func (m *SnippetModel) Insert(ctx context.Context, userID int, teamID int, title string, content string, expires int, visibility string) (int, error) {
+	stmt := `INSERT INTO snippets (title, content, created, expires, user_id, visibility, team_id)
+    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?)`
 
 	// "Exec()" metoda se koristi nad "connection pool"-om, kako bi smo izvršili naredbu
 	// ona će vratiti "sql.Result" tip, koji sadrži informacije o izvršavanju naredbe
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := m.DB.ExecContext(ctx, stmt, title, content, expires, nullableID(userID), visibility, nullableID(teamID))
 	if err != nil {
-		return 0, err
+		return 0, wrapTimeout(err)
 	}
 
 	// "id" koji generiše baza nakon izvršavanja komande
@@ -123,3 +225,221 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 
 	return int(id), nil
 }
+
+// "Update" mijenja naslov, sadržaj i vidljivost "snippet"-a koji još nije istekao
+// autor, tim i rok trajanja ostaju isti
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, visibility string) error {
+	stmt := `UPDATE snippets SET title = ?, content = ?, visibility = ? WHERE id = ? AND expires > UTC_TIMESTAMP()`
+
+	result, err := m.DB.ExecContext(ctx, stmt, title, content, visibility, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	// "RowsAffected" je "0" i kada se ništa nije promijenilo, pa u tom slučaju provjeravamo da li "snippet" postoji
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		var exists bool
+		err = m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP())`, id).Scan(&exists)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// "List" vraća sve "snippet"-e (i one koji su istekli), od najnovijeg ka najstarijem
+// koristi se za moderaciju, pa nema filtera po "expires" koloni
+func (m *SnippetModel) List(ctx context.Context, limit int, offset int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	return m.query(ctx, stmt, limit, offset)
+}
+
+// "ListForUser" vraća javne "snippet"-e korisnika koji još nisu istekli (za profil korisnika)
+func (m *SnippetModel) ListForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.user_id = ? AND s.visibility = 'public' AND s.expires > UTC_TIMESTAMP()
+    ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	return m.query(ctx, stmt, userID, limit, offset)
+}
+
+// "ListActiveForUser" vraća sve "snippet"-e korisnika koji još nisu istekli, uključujući privatne (za API)
+// ukupan broj je "Active" iz "CountsForUser"
+func (m *SnippetModel) ListActiveForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.user_id = ? AND s.expires > UTC_TIMESTAMP()
+    ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	return m.query(ctx, stmt, userID, limit, offset)
+}
+
+// "ListForTeam" vraća sve "snippet"-e tima koji još nisu istekli (za stranicu tima)
+func (m *SnippetModel) ListForTeam(ctx context.Context, teamID int, limit int, offset int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.team_id = ? AND s.expires > UTC_TIMESTAMP()
+    ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	return m.query(ctx, stmt, teamID, limit, offset)
+}
+
+// "query" izvršava upit koji vraća listu "snippet"-a
+func (m *SnippetModel) query(ctx context.Context, stmt string, args ...any) ([]Snippet, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		var s Snippet
+		err = scanSnippet(rows, &s)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Counts(ctx context.Context) (SnippetCounts, error) {
+	var c SnippetCounts
+
+	stmt := `SELECT COUNT(*), COALESCE(SUM(expires > UTC_TIMESTAMP()), 0),
+    COALESCE(SUM(expires > UTC_TIMESTAMP() AND visibility = 'public'), 0) FROM snippets`
+
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&c.Total, &c.Active, &c.Public)
+	if err != nil {
+		return SnippetCounts{}, wrapTimeout(err)
+	}
+	c.Expired = c.Total - c.Active
+
+	return c, nil
+}
+
+func (m *SnippetModel) CountsForUser(ctx context.Context, userID int) (SnippetCounts, error) {
+	var c SnippetCounts
+
+	stmt := `SELECT COUNT(*), COALESCE(SUM(expires > UTC_TIMESTAMP()), 0),
+    COALESCE(SUM(expires > UTC_TIMESTAMP() AND visibility = 'public'), 0) FROM snippets WHERE user_id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&c.Total, &c.Active, &c.Public)
+	if err != nil {
+		return SnippetCounts{}, wrapTimeout(err)
+	}
+	c.Expired = c.Total - c.Active
+
+	return c, nil
+}
+
+func (m *SnippetModel) CountsForTeam(ctx context.Context, teamID int) (SnippetCounts, error) {
+	var c SnippetCounts
+
+	stmt := `SELECT COUNT(*), COALESCE(SUM(expires > UTC_TIMESTAMP()), 0),
+    COALESCE(SUM(expires > UTC_TIMESTAMP() AND visibility = 'public'), 0) FROM snippets WHERE team_id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, teamID).Scan(&c.Total, &c.Active, &c.Public)
+	if err != nil {
+		return SnippetCounts{}, wrapTimeout(err)
+	}
+	c.Expired = c.Total - c.Active
+
+	return c, nil
+}
+
+// "Delete" trajno briše "snippet" - vraća "ErrNoRecord" ukoliko ne postoji
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "DeleteExpired" trajno briše "snippet"-e koji su istekli prije više od "olderThan"
+// "snippet"-i za koje "webhook worker" (dio "cmd/web" procesa) još nije poslao "snippet.expired" događaj se preskaču,
+// osim ukoliko je postavljen "includeUnnotified" - tada taj događaj za njih nikad neće biti poslat
+// vraća broj obrisanih i broj preskočenih "snippet"-a
+func (m *SnippetModel) DeleteExpired(ctx context.Context, olderThan time.Duration, includeUnnotified bool) (int, int, error) {
+	seconds := int64(olderThan.Seconds())
+
+	var skipped int
+	if !includeUnnotified {
+		stmt := `SELECT COUNT(*) FROM snippets WHERE expires <= UTC_TIMESTAMP() - INTERVAL ? SECOND AND expiry_notified = FALSE`
+
+		err := m.DB.QueryRowContext(ctx, stmt, seconds).Scan(&skipped)
+		if err != nil {
+			return 0, 0, wrapTimeout(err)
+		}
+	}
+
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() - INTERVAL ? SECOND AND (expiry_notified = TRUE OR ?)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, seconds, includeUnnotified)
+	if err != nil {
+		return 0, 0, wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(rows), skipped, nil
+}
+
+// "AllForUser" vraća sve "snippet"-e korisnika, uključujući privatne i one koji su istekli (za izvoz podataka)
+func (m *SnippetModel) AllForUser(ctx context.Context, userID int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.user_id = ? ORDER BY s.id`
+
+	return m.query(ctx, stmt, userID)
+}
+
+// "DeleteForUser" briše "snippet"-e korisnika prije brisanja naloga
+// ukoliko je "anonymizePublic" postavljen, javni "snippet"-i ostaju, ali bez autora
+// "snippet"-i tima pripadaju timu, pa uvijek ostaju (bez autora)
+func (m *SnippetModel) DeleteForUser(ctx context.Context, userID int, anonymizePublic bool) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET user_id = NULL WHERE user_id = ? AND (team_id IS NOT NULL OR (? AND visibility = 'public'))`
+
+	_, err = tx.ExecContext(ctx, stmt, userID, anonymizePublic)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	stmt = `DELETE FROM snippets WHERE user_id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, userID)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	return wrapTimeout(tx.Commit())
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"snippetbox.lazarmrkic.com/internal/cache"
+	"sync"
+	"time"
+)
+
+// "CachedSnippetModel" je "read-through" keš ispred nekog "SnippetModelInterface"-a
+// "Get" i "Latest" prvo provjeravaju keš, a tek nakon promašaja idu ka bazi
+// metode koje mijenjaju podatke uvijek idu ka bazi, nakon čega se odgovarajući unosi poništavaju
+//
+// BITNO:
+// "Get" koji je promašio keš može da pročita stari red iz baze i da ga upiše u keš tek nakon poništavanja
+// zato svako poništavanje povećava "generation", a upis nakon promašaja se odbacuje ukoliko se generacija promijenila
+type CachedSnippetModel struct {
+	Model    SnippetModelInterface
+	snippets *cache.LRU[int, Snippet]
+	latest   *cache.LRU[struct{}, []Snippet]
+
+	mu         sync.Mutex
+	generation uint64
+}
+
+// "SnippetCacheStats" sadrži brojače za keš pojedinačnih "snippet"-a i keš liste najnovijih
+type SnippetCacheStats struct {
+	Snippets cache.Stats
+	Latest   cache.Stats
+}
+
+// "NewCachedSnippetModel" omotava dati model kešom od "size" unosa, koji traju najviše "ttl"
+func NewCachedSnippetModel(model SnippetModelInterface, size int, ttl time.Duration) *CachedSnippetModel {
+	return &CachedSnippetModel{
+		Model:    model,
+		snippets: cache.New[int, Snippet](size, ttl),
+		latest:   cache.New[struct{}, []Snippet](1, ttl),
+	}
+}
+
+func (m *CachedSnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	if s, ok := m.snippets.Get(id); ok {
+		return s, nil
+	}
+
+	generation := m.currentGeneration()
+	s, err := m.Model.Get(ctx, id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	// unos ne smije da nadživi "snippet", pa je "Expires" gornja granica trajanja
+	m.fill(generation, func() {
+		m.snippets.Set(id, s, s.Expires)
+	})
+
+	return s, nil
+}
+
+func (m *CachedSnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	if snippets, ok := m.latest.Get(struct{}{}); ok {
+		return slices.Clone(snippets), nil
+	}
+
+	generation := m.currentGeneration()
+	snippets, err := m.Model.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// lista važi dok ne istekne prvi "snippet" iz nje
+	var expires time.Time
+	for _, s := range snippets {
+		if expires.IsZero() || s.Expires.Before(expires) {
+			expires = s.Expires
+		}
+	}
+	m.fill(generation, func() {
+		m.latest.Set(struct{}{}, slices.Clone(snippets), expires)
+	})
+
+	return snippets, nil
+}
+
+func (m *CachedSnippetModel) Insert(ctx context.Context, userID int, teamID int, title string, content string, expires int, visibility string) (int, error) {
+	id, err := m.Model.Insert(ctx, userID, teamID, title, content, expires, visibility)
+	if err != nil {
+		return 0, err
+	}
+
+	// novi "snippet" mijenja listu najnovijih
+	m.invalidate(func() {
+		m.latest.Purge()
+	})
+
+	return id, nil
+}
+
+func (m *CachedSnippetModel) Update(ctx context.Context, id int, title string, content string, visibility string) error {
+	err := m.Model.Update(ctx, id, title, content, visibility)
+	if err != nil {
+		return err
+	}
+
+	// izmijenjeni "snippet" može biti i u listi najnovijih (ili tek sada postati javan)
+	m.invalidate(func() {
+		m.snippets.Delete(id)
+		m.latest.Purge()
+	})
+
+	return nil
+}
+
+// liste sa paginacijom i statistika se ne keširaju - broj kombinacija stranica je prevelik
+func (m *CachedSnippetModel) List(ctx context.Context, limit int, offset int) ([]Snippet, error) {
+	return m.Model.List(ctx, limit, offset)
+}
+
+func (m *CachedSnippetModel) Counts(ctx context.Context) (SnippetCounts, error) {
+	return m.Model.Counts(ctx)
+}
+
+func (m *CachedSnippetModel) ListForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error) {
+	return m.Model.ListForUser(ctx, userID, limit, offset)
+}
+
+func (m *CachedSnippetModel) CountsForUser(ctx context.Context, userID int) (SnippetCounts, error) {
+	return m.Model.CountsForUser(ctx, userID)
+}
+
+func (m *CachedSnippetModel) ListActiveForUser(ctx context.Context, userID int, limit int, offset int) ([]Snippet, error) {
+	return m.Model.ListActiveForUser(ctx, userID, limit, offset)
+}
+
+func (m *CachedSnippetModel) ListForTeam(ctx context.Context, teamID int, limit int, offset int) ([]Snippet, error) {
+	return m.Model.ListForTeam(ctx, teamID, limit, offset)
+}
+
+func (m *CachedSnippetModel) CountsForTeam(ctx context.Context, teamID int) (SnippetCounts, error) {
+	return m.Model.CountsForTeam(ctx, teamID)
+}
+
+func (m *CachedSnippetModel) Delete(ctx context.Context, id int) error {
+	err := m.Model.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// obrisani "snippet" ne smije da ostane ni u kešu, ni u listi najnovijih
+	m.invalidate(func() {
+		m.snippets.Delete(id)
+		m.latest.Purge()
+	})
+
+	return nil
+}
+
+func (m *CachedSnippetModel) AllForUser(ctx context.Context, userID int) ([]Snippet, error) {
+	return m.Model.AllForUser(ctx, userID)
+}
+
+// istekli "snippet"-i nisu u kešu (unos traje najduže do "Expires"), pa keš ne treba poništavati
+func (m *CachedSnippetModel) DeleteExpired(ctx context.Context, olderThan time.Duration, includeUnnotified bool) (int, int, error) {
+	return m.Model.DeleteExpired(ctx, olderThan, includeUnnotified)
+}
+
+func (m *CachedSnippetModel) DeleteForUser(ctx context.Context, userID int, anonymizePublic bool) error {
+	err := m.Model.DeleteForUser(ctx, userID, anonymizePublic)
+	if err != nil {
+		return err
+	}
+
+	// ne znamo koji su "snippet"-i bili u kešu, pa ga praznimo cijelog
+	// brisanje naloga je rijetko, pa to nije problem za performanse
+	m.invalidate(func() {
+		m.snippets.Purge()
+		m.latest.Purge()
+	})
+
+	return nil
+}
+
+// "PurgeLatest" briše keširanu listu najnovijih "snippet"-a
+// koristi se nakon upisa koji zaobilaze ovaj model (npr. uvoz preko "TransferModel"-a)
+// postojeći "snippet"-i se uvozom ne mijenjaju, pa keš pojedinačnih "snippet"-a ostaje validan
+func (m *CachedSnippetModel) PurgeLatest() {
+	m.invalidate(func() {
+		m.latest.Purge()
+	})
+}
+
+// "Stats" vraća broj pogodaka i promašaja, kako bi se lakše podesili "size" i "ttl"
+func (m *CachedSnippetModel) Stats() SnippetCacheStats {
+	return SnippetCacheStats{
+		Snippets: m.snippets.Stats(),
+		Latest:   m.latest.Stats(),
+	}
+}
+
+// "currentGeneration" se čita prije odlaska ka bazi, a kasnije se prosljeđuje "fill" metodi
+func (m *CachedSnippetModel) currentGeneration() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.generation
+}
+
+// "fill" upisuje rezultat iz baze u keš, samo ukoliko u međuvremenu nije bilo poništavanja
+// provjera i upis se izvršavaju pod istim "lock"-om, kako poništavanje ne bi moglo da se desi između njih
+func (m *CachedSnippetModel) fill(generation uint64, set func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.generation == generation {
+		set()
+	}
+}
+
+// "invalidate" povećava generaciju i poništava unose
+// svi upisi koji su krenuli ka bazi prije poništavanja će zbog toga biti odbačeni
+func (m *CachedSnippetModel) invalidate(purge func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generation++
+	purge()
+}
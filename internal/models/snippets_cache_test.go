@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// "stubSnippetModel" čuva "snippet"-e u memoriji i broji pozive ka "bazi"
+// metode koje testovi ne koriste ostaju neimplementirane (ugrađeni "nil" interfejs)
+type stubSnippetModel struct {
+	SnippetModelInterface
+
+	mu       sync.Mutex
+	snippets map[int]Snippet
+	gets     int
+	latests  int
+	// ukoliko nije "nil", "Get" javlja da je pročitao red i čeka prije nego što ga vrati
+	readDone chan struct{}
+	release  chan struct{}
+}
+
+func newStubSnippetModel(snippets ...Snippet) *stubSnippetModel {
+	m := &stubSnippetModel{snippets: make(map[int]Snippet)}
+	for _, s := range snippets {
+		m.snippets[s.ID] = s
+	}
+	return m
+}
+
+func (m *stubSnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	m.mu.Lock()
+	m.gets++
+	s, ok := m.snippets[id]
+	readDone, release := m.readDone, m.release
+	m.mu.Unlock()
+
+	if readDone != nil {
+		close(readDone)
+		<-release
+	}
+
+	if !ok {
+		return Snippet{}, ErrNoRecord
+	}
+	return s, nil
+}
+
+func (m *stubSnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.latests++
+	var snippets []Snippet
+	for _, s := range m.snippets {
+		snippets = append(snippets, s)
+	}
+	return snippets, nil
+}
+
+func (m *stubSnippetModel) Insert(ctx context.Context, userID int, teamID int, title string, content string, expires int, visibility string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := len(m.snippets) + 1
+	m.snippets[id] = Snippet{ID: id, Title: title, Content: content, Expires: time.Now().AddDate(0, 0, expires), Visibility: visibility}
+	return id, nil
+}
+
+func (m *stubSnippetModel) Update(ctx context.Context, id int, title string, content string, visibility string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.snippets[id]
+	s.Title, s.Content, s.Visibility = title, content, visibility
+	m.snippets[id] = s
+	return nil
+}
+
+func (m *stubSnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.snippets, id)
+	return nil
+}
+
+func TestCachedSnippetModel(t *testing.T) {
+	ctx := context.Background()
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	t.Run("Get is served from cache", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Title: "One", Expires: tomorrow})
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			s, err := m.Get(ctx, 1)
+			assert.NilError(t, err)
+			assert.Equal(t, s.Title, "One")
+		}
+		assert.Equal(t, stub.gets, 1)
+	})
+
+	t.Run("Update invalidates", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Title: "One", Expires: tomorrow})
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		m.Get(ctx, 1)
+		m.Latest(ctx)
+		assert.NilError(t, m.Update(ctx, 1, "Changed", "", VisibilityPublic))
+
+		s, err := m.Get(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "Changed")
+
+		latest, err := m.Latest(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, latest[0].Title, "Changed")
+		assert.Equal(t, stub.latests, 2)
+	})
+
+	t.Run("Delete invalidates", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Title: "One", Expires: tomorrow})
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		m.Get(ctx, 1)
+		m.Latest(ctx)
+		assert.NilError(t, m.Delete(ctx, 1))
+
+		_, err := m.Get(ctx, 1)
+		assert.Equal(t, err, ErrNoRecord)
+
+		latest, err := m.Latest(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(latest), 0)
+	})
+
+	t.Run("Insert purges latest", func(t *testing.T) {
+		stub := newStubSnippetModel()
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		m.Latest(ctx)
+		_, err := m.Insert(ctx, 1, 0, "New", "", 7, VisibilityPublic)
+		assert.NilError(t, err)
+
+		latest, err := m.Latest(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(latest), 1)
+		assert.Equal(t, stub.latests, 2)
+	})
+
+	t.Run("PurgeLatest", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Expires: tomorrow})
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		m.Latest(ctx)
+		m.Latest(ctx)
+		m.PurgeLatest()
+		m.Latest(ctx)
+		assert.Equal(t, stub.latests, 2)
+	})
+
+	t.Run("Entries expire after TTL", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Expires: tomorrow})
+		m := NewCachedSnippetModel(stub, 10, 10*time.Millisecond)
+
+		m.Get(ctx, 1)
+		time.Sleep(20 * time.Millisecond)
+		m.Get(ctx, 1)
+		assert.Equal(t, stub.gets, 2)
+	})
+
+	t.Run("Entries do not outlive the snippet", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Expires: time.Now().Add(-time.Second)})
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		m.Get(ctx, 1)
+		m.Get(ctx, 1)
+		assert.Equal(t, stub.gets, 2)
+	})
+
+	t.Run("Late fill after invalidation is dropped", func(t *testing.T) {
+		stub := newStubSnippetModel(Snippet{ID: 1, Title: "Old", Expires: tomorrow})
+		readDone, release := make(chan struct{}), make(chan struct{})
+		stub.readDone, stub.release = readDone, release
+		m := NewCachedSnippetModel(stub, 10, time.Minute)
+
+		// "Get" čita stari red, a zatim čeka dok se "snippet" ne izmijeni
+		done := make(chan Snippet)
+		go func() {
+			s, _ := m.Get(ctx, 1)
+			done <- s
+		}()
+		<-readDone
+
+		// sledeći pozivi "Get" metode više ne čekaju
+		stub.mu.Lock()
+		stub.readDone, stub.release = nil, nil
+		stub.mu.Unlock()
+		assert.NilError(t, m.Update(ctx, 1, "New", "", VisibilityPublic))
+
+		close(release)
+		assert.Equal(t, (<-done).Title, "Old")
+
+		s, err := m.Get(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "New")
+	})
+}
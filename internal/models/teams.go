@@ -0,0 +1,416 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// tabele za timove, članstva i pozivnice u tim:
+//
+//	CREATE TABLE teams (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    name VARCHAR(100) NOT NULL,
+//	    created DATETIME NOT NULL
+//	);
+//
+//	CREATE TABLE team_members (
+//	    team_id INTEGER NOT NULL,
+//	    user_id INTEGER NOT NULL,
+//	    role VARCHAR(10) NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    PRIMARY KEY (team_id, user_id),
+//	    CONSTRAINT team_members_fk_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+//	    CONSTRAINT team_members_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//	);
+//
+//	CREATE TABLE team_invitations (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    team_id INTEGER NOT NULL,
+//	    email VARCHAR(255) NOT NULL,
+//	    role VARCHAR(10) NOT NULL,
+//	    token_hash BINARY(32) NOT NULL,
+//	    invited_by INTEGER NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    expires DATETIME NOT NULL,
+//	    CONSTRAINT team_invitations_uc_token_hash UNIQUE (token_hash),
+//	    CONSTRAINT team_invitations_fk_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+//	);
+//
+//	ALTER TABLE snippets ADD team_id INTEGER NULL;
+//	ALTER TABLE snippets ADD CONSTRAINT snippets_fk_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL;
+
+// uloge članova tima
+// "owner" upravlja članovima, "editor" kreira i mijenja "snippet"-e tima, a "viewer" ih samo vidi
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleEditor = "editor"
+	TeamRoleViewer = "viewer"
+)
+
+// "TeamRoles" sadrži sve uloge, od najjače ka najslabijoj
+var TeamRoles = []string{TeamRoleOwner, TeamRoleEditor, TeamRoleViewer}
+
+// "ErrLastOwner" se vraća kada bi izmjena ostavila tim bez vlasnika
+var ErrLastOwner = errors.New("models: team must have at least one owner")
+
+// "ErrInvalidTeamInvitation" se vraća kada pozivnica u tim ne postoji, istekla je ili je poslata na drugu adresu
+var ErrInvalidTeamInvitation = errors.New("models: invalid team invitation")
+
+// "CanEdit" vraća "true" za uloge koje smiju da kreiraju i mijenjaju "snippet"-e tima
+func CanEdit(role string) bool {
+	return role == TeamRoleOwner || role == TeamRoleEditor
+}
+
+type Team struct {
+	ID      int
+	Name    string
+	Created time.Time
+}
+
+// "TeamMembership" je tim zajedno sa ulogom korisnika u njemu
+type TeamMembership struct {
+	Team
+	Role string
+}
+
+type TeamMember struct {
+	UserID  int
+	Name    string
+	Email   string
+	Role    string
+	Created time.Time
+}
+
+type TeamInvitation struct {
+	ID      int
+	TeamID  int
+	Email   string
+	Role    string
+	Created time.Time
+	Expires time.Time
+}
+
+type TeamModelInterface interface {
+	Insert(ctx context.Context, name string, ownerID int) (int, error)
+	Get(ctx context.Context, id int) (Team, error)
+	ListForUser(ctx context.Context, userID int) ([]TeamMembership, error)
+	Members(ctx context.Context, teamID int) ([]TeamMember, error)
+	Role(ctx context.Context, teamID int, userID int) (string, error)
+	SetRole(ctx context.Context, teamID int, userID int, role string) error
+	RemoveMember(ctx context.Context, teamID int, userID int) error
+	Invite(ctx context.Context, teamID int, email string, role string, invitedBy int, ttl time.Duration) (string, error)
+	Invitations(ctx context.Context, teamID int) ([]TeamInvitation, error)
+	DeleteInvitation(ctx context.Context, teamID int, id int) error
+	AcceptInvitation(ctx context.Context, plaintext string, userID int, email string) (int, error)
+}
+
+type TeamModel struct {
+	DB *sql.DB
+}
+
+// "Insert" kreira tim i dodaje korisnika kao vlasnika - oba upisa su unutar jedne transakcije
+func (m *TeamModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO teams (name, created) VALUES (?, UTC_TIMESTAMP())`, name)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO team_members (team_id, user_id, role, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.ExecContext(ctx, stmt, id, ownerID, TeamRoleOwner)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return int(id), nil
+}
+
+func (m *TeamModel) Get(ctx context.Context, id int) (Team, error) {
+	var t Team
+
+	stmt := `SELECT id, name, created FROM teams WHERE id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&t.ID, &t.Name, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Team{}, ErrNoRecord
+		} else {
+			return Team{}, wrapTimeout(err)
+		}
+	}
+
+	return t, nil
+}
+
+// "ListForUser" vraća timove čiji je korisnik član, zajedno sa njegovom ulogom
+func (m *TeamModel) ListForUser(ctx context.Context, userID int) ([]TeamMembership, error) {
+	stmt := `SELECT t.id, t.name, t.created, tm.role FROM teams t
+    JOIN team_members tm ON tm.team_id = t.id
+    WHERE tm.user_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var teams []TeamMembership
+	for rows.Next() {
+		var t TeamMembership
+		err = rows.Scan(&t.ID, &t.Name, &t.Created, &t.Role)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		teams = append(teams, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return teams, nil
+}
+
+func (m *TeamModel) Members(ctx context.Context, teamID int) ([]TeamMember, error) {
+	stmt := `SELECT u.id, u.name, u.email, tm.role, tm.created FROM team_members tm
+    JOIN users u ON u.id = tm.user_id
+    WHERE tm.team_id = ? ORDER BY FIELD(tm.role, 'owner', 'editor', 'viewer'), u.name`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var members []TeamMember
+	for rows.Next() {
+		var tm TeamMember
+		err = rows.Scan(&tm.UserID, &tm.Name, &tm.Email, &tm.Role, &tm.Created)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		members = append(members, tm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return members, nil
+}
+
+// "Role" vraća ulogu korisnika u timu, odnosno "ErrNoRecord" ukoliko korisnik nije član
+func (m *TeamModel) Role(ctx context.Context, teamID int, userID int) (string, error) {
+	var role string
+
+	stmt := `SELECT role FROM team_members WHERE team_id = ? AND user_id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, teamID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", wrapTimeout(err)
+		}
+	}
+
+	return role, nil
+}
+
+// "SetRole" mijenja ulogu člana
+// vlasnik ne može da izgubi ulogu ukoliko je jedini vlasnik tima
+func (m *TeamModel) SetRole(ctx context.Context, teamID int, userID int, role string) error {
+	return m.changeMember(ctx, teamID, userID, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.ExecContext(ctx, `UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?`, role, teamID, userID)
+	}, role != TeamRoleOwner)
+}
+
+// "RemoveMember" uklanja člana iz tima (ili korisnik sam napušta tim)
+func (m *TeamModel) RemoveMember(ctx context.Context, teamID int, userID int) error {
+	return m.changeMember(ctx, teamID, userID, func(tx *sql.Tx) (sql.Result, error) {
+		return tx.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	}, true)
+}
+
+// "changeMember" izvršava izmjenu člana unutar transakcije
+// ukoliko izmjena oduzima ulogu vlasnika ("demotes"), prvo provjeravamo da tim ima još nekog vlasnika
+// "FOR UPDATE" zaključava redove vlasnika, pa dva vlasnika ne mogu istovremeno da napuste tim
+func (m *TeamModel) changeMember(ctx context.Context, teamID int, userID int, change func(tx *sql.Tx) (sql.Result, error), demotes bool) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	if demotes {
+		var owners int
+		var isOwner bool
+
+		stmt := `SELECT COUNT(*), COALESCE(SUM(user_id = ?), 0) FROM
+    (SELECT user_id FROM team_members WHERE team_id = ? AND role = 'owner' FOR UPDATE) o`
+
+		err = tx.QueryRowContext(ctx, stmt, userID, teamID).Scan(&owners, &isOwner)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+
+		if isOwner && owners == 1 {
+			return ErrLastOwner
+		}
+	}
+
+	result, err := change(tx)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// "UPDATE" ne mijenja red ukoliko je uloga ista, pa provjeravamo da li je korisnik uopšte član
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM team_members WHERE team_id = ? AND user_id = ?)`, teamID, userID).Scan(&exists)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return wrapTimeout(tx.Commit())
+}
+
+// "Invite" kreira pozivnicu u tim za datu "email" adresu i vraća "plain-text" token za link iz mejla
+func (m *TeamModel) Invite(ctx context.Context, teamID int, email string, role string, invitedBy int, ttl time.Duration) (string, error) {
+	token, err := generateToken(invitedBy, ttl, "")
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO team_invitations (team_id, email, role, token_hash, invited_by, created, expires)
+    VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, teamID, email, role, token.Hash, invitedBy, token.Expiry.UTC())
+	if err != nil {
+		return "", wrapTimeout(err)
+	}
+
+	return token.Plaintext, nil
+}
+
+// "Invitations" vraća pozivnice u tim koje još nisu prihvaćene ni istekle
+func (m *TeamModel) Invitations(ctx context.Context, teamID int) ([]TeamInvitation, error) {
+	stmt := `SELECT id, team_id, email, role, created, expires FROM team_invitations
+    WHERE team_id = ? AND expires > UTC_TIMESTAMP() ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var invitations []TeamInvitation
+	for rows.Next() {
+		var i TeamInvitation
+		err = rows.Scan(&i.ID, &i.TeamID, &i.Email, &i.Role, &i.Created, &i.Expires)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return invitations, nil
+}
+
+func (m *TeamModel) DeleteInvitation(ctx context.Context, teamID int, id int) error {
+	stmt := `DELETE FROM team_invitations WHERE team_id = ? AND id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, teamID, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "AcceptInvitation" dodaje korisnika u tim i briše pozivnicu
+// pozivnicu može da prihvati samo korisnik sa "email" adresom na koju je poslata
+// ukoliko je korisnik već član tima, njegova uloga se ne mijenja
+func (m *TeamModel) AcceptInvitation(ctx context.Context, plaintext string, userID int, email string) (int, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	var id, teamID int
+	var role string
+
+	stmt := `SELECT id, team_id, role FROM team_invitations
+    WHERE token_hash = ? AND email = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, stmt, hash[:], email).Scan(&id, &teamID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidTeamInvitation
+		} else {
+			return 0, wrapTimeout(err)
+		}
+	}
+
+	stmt = `INSERT INTO team_members (team_id, user_id, role, created) VALUES (?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE role = role`
+
+	_, err = tx.ExecContext(ctx, stmt, teamID, userID, role)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM team_invitations WHERE id = ?`, id)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return teamID, nil
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+// tabela za jednokratne tokene:
+//
+//	CREATE TABLE tokens (
+//	    hash BINARY(32) NOT NULL PRIMARY KEY,
+//	    user_id INTEGER NOT NULL,
+//	    expiry DATETIME NOT NULL,
+//	    scope VARCHAR(32) NOT NULL,
+//	    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//	);
+
+// "scope" određuje za šta se token može iskoristiti
+// token za reset lozinke, na primjer, ne smije da posluži za neku drugu akciju
+const (
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
+)
+
+// "Token" sadrži "plain-text" vrijednost, koja se šalje korisniku (npr. unutar linka u mejlu)
+// u bazi se čuva samo SHA-256 "hash" te vrijednosti, pa curenje baze ne otkriva važeće tokene
+type Token struct {
+	Plaintext string
+	Hash      []byte
+	UserID    int
+	Expiry    time.Time
+	Scope     string
+}
+
+type TokenModelInterface interface {
+	New(ctx context.Context, userID int, ttl time.Duration, scope string) (Token, error)
+	Consume(ctx context.Context, scope string, plaintext string) (int, error)
+	DeleteAllForUser(ctx context.Context, scope string, userID int) error
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+func generateToken(userID int, ttl time.Duration, scope string) (Token, error) {
+	token := Token{
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	// 16 nasumičnih bajtova daje 128 bita entropije
+	// "base32" bez "padding"-a daje string od 26 karaktera, koji je bezbjedan za URL
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return Token{}, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+// "New" generiše novi token i upisuje njegov "hash" u bazu
+func (m *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return Token{}, err
+	}
+
+	stmt := `INSERT INTO tokens (hash, user_id, expiry, scope) VALUES (?, ?, ?, ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, token.Hash, token.UserID, token.Expiry.UTC(), token.Scope)
+	if err != nil {
+		return Token{}, wrapTimeout(err)
+	}
+
+	return token, nil
+}
+
+// "Consume" provjerava token i odmah ga briše iz baze, pa se on može iskoristiti samo jednom
+// provjera i brisanje se izvršavaju unutar iste transakcije, kako dva istovremena zahtjeva ne bi iskoristila isti token
+// ukoliko token ne postoji ili je istekao, vraća se "ErrNoRecord"
+func (m *TokenModel) Consume(ctx context.Context, scope string, plaintext string) (int, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+	// "Rollback" nakon uspješnog "Commit"-a ne radi ništa
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, stmt, hash[:], scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, wrapTimeout(err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE hash = ?`, hash[:])
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return userID, nil
+}
+
+// "DeleteAllForUser" briše sve tokene korisnika sa datim "scope"-om
+// npr. nakon reseta lozinke, ostali linkovi za reset više ne smiju da važe
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int) error {
+	stmt := `DELETE FROM tokens WHERE scope = ? AND user_id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, scope, userID)
+	return wrapTimeout(err)
+}
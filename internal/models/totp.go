@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+)
+
+// kolone i tabela za dvofaktorsku autentifikaciju (TOTP):
+//
+//	ALTER TABLE users
+//	    ADD totp_secret VARCHAR(64) NULL,
+//	    ADD totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+//	    ADD totp_last_step BIGINT NOT NULL DEFAULT 0;
+//
+//	CREATE TABLE recovery_codes (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    user_id INTEGER NOT NULL,
+//	    code_hash BINARY(32) NOT NULL,
+//	    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//	);
+
+// "TOTPEnable" čuva tajni ključ i "hash"-eve kodova za oporavak
+// sve se izvršava unutar jedne transakcije, kako korisnik ne bi ostao sa uključenim TOTP-om bez kodova za oporavak
+// "recoveryCodes" moraju biti normalizovani (vidi "totp.NormalizeRecoveryCode")
+func (m *UserModel) TOTPEnable(ctx context.Context, id int, secret string, recoveryCodes []string) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_secret = ?, totp_enabled = TRUE, totp_last_step = 0 WHERE id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, secret, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	for _, code := range recoveryCodes {
+		hash := sha256.Sum256([]byte(code))
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, id, hash[:])
+		if err != nil {
+			return wrapTimeout(err)
+		}
+	}
+
+	return wrapTimeout(tx.Commit())
+}
+
+// "TOTPDisable" briše tajni ključ i sve kodove za oporavak
+func (m *UserModel) TOTPDisable(ctx context.Context, id int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	return wrapTimeout(tx.Commit())
+}
+
+// "TOTPSecret" vraća tajni ključ korisnika koji ima uključen TOTP
+// ukoliko TOTP nije uključen, vraća se "ErrNoRecord"
+func (m *UserModel) TOTPSecret(ctx context.Context, id int) (string, error) {
+	var secret string
+
+	stmt := `SELECT totp_secret FROM users WHERE id = ? AND totp_enabled = TRUE`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", wrapTimeout(err)
+		}
+	}
+
+	return secret, nil
+}
+
+// "TOTPUseStep" bilježi period iskorišćenog koda
+// vraća "false" ukoliko je kod iz tog (ili kasnijeg) perioda već iskorišćen - tako se sprječava ponovna upotreba istog koda
+func (m *UserModel) TOTPUseStep(ctx context.Context, id int, step int64) (bool, error) {
+	stmt := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, step, id, step)
+	if err != nil {
+		return false, wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// "UseRecoveryCode" briše kod za oporavak, ukoliko on postoji
+// kod mora biti normalizovan, a svaki kod se može iskoristiti samo jednom
+func (m *UserModel) UseRecoveryCode(ctx context.Context, id int, code string) (bool, error) {
+	hash := sha256.Sum256([]byte(code))
+
+	stmt := `DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ? LIMIT 1`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, hash[:])
+	if err != nil {
+		return false, wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"snippetbox.lazarmrkic.com/internal/password"
+)
+
+// "TransferModel" čita i upisuje podatke za prenos između okruženja (vidjeti paket "transfer")
+// izvoz prolazi kroz redove jedan po jedan, pa se ni velika baza ne učitava cijela u memoriju
+
+// ishod uvoza jednog zapisa
+const (
+	// zapis je upisan pod istim "ID"-jem
+	ImportInserted = "inserted"
+	// "ID" je zauzet drugim zapisom, pa je zapis upisan pod novim "ID"-jem
+	ImportRenumbered = "renumbered"
+	// zapis već postoji (korisnik sa istom "email" adresom, odnosno "snippet" sa istim naslovom, sadržajem i vremenom kreiranja)
+	ImportExisting = "existing"
+)
+
+type TransferModelInterface interface {
+	EachUser(ctx context.Context, fn func(User) error) error
+	EachSnippet(ctx context.Context, fn func(s Snippet, authorEmail string) error) error
+	UserIDByEmail(ctx context.Context, email string) (int, error)
+	ImportUser(ctx context.Context, u User) (int, string, error)
+	ImportSnippet(ctx context.Context, s Snippet) (int, string, error)
+}
+
+// "Passwords" se koristi za "hash" nasumične lozinke uvezenih korisnika
+type TransferModel struct {
+	DB        *sql.DB
+	Passwords password.Hasher
+}
+
+// "EachUser" poziva "fn" za svakog korisnika, redom po "ID"-ju
+// "hash" lozinke i TOTP podaci se namjerno ne čitaju
+func (m *TransferModel) EachUser(ctx context.Context, fn func(User) error) error {
+	stmt := `SELECT id, name, email, created, activated, totp_enabled, role, disabled FROM users ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated, &u.TOTPEnabled, &u.Role, &u.Disabled)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+
+		err = fn(u)
+		if err != nil {
+			return err
+		}
+	}
+
+	return wrapTimeout(rows.Err())
+}
+
+// "EachSnippet" poziva "fn" za svaki "snippet" (i one koji su istekli), redom po "ID"-ju
+// "authorEmail" je prazan string ukoliko "snippet" nema autora
+func (m *TransferModel) EachSnippet(ctx context.Context, fn func(s Snippet, authorEmail string) error) error {
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.visibility, s.team_id, t.name, u.email
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id ORDER BY s.id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Snippet
+		var userID, teamID sql.NullInt64
+		var authorName, teamName, authorEmail sql.NullString
+
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &userID, &authorName, &s.Visibility, &teamID, &teamName, &authorEmail)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+		s.UserID = int(userID.Int64)
+		s.AuthorName = authorName.String
+		s.TeamID = int(teamID.Int64)
+		s.TeamName = teamName.String
+
+		err = fn(s, authorEmail.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	return wrapTimeout(rows.Err())
+}
+
+// "UserIDByEmail" vraća "ErrNoRecord" ukoliko korisnik ne postoji
+func (m *TransferModel) UserIDByEmail(ctx context.Context, email string) (int, error) {
+	var id int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT id FROM users WHERE email = ?`, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, wrapTimeout(err)
+	}
+
+	return id, nil
+}
+
+// "ImportUser" upisuje korisnika i vraća njegov "ID" u ovoj bazi
+// korisnik sa istom "email" adresom se ne mijenja - vraća se njegov postojeći "ID"
+// uvezeni korisnik dobija nasumičnu lozinku, koju niko ne zna - prijavljuje se nakon "forgot password" postupka
+func (m *TransferModel) ImportUser(ctx context.Context, u User) (int, string, error) {
+	id, err := m.UserIDByEmail(ctx, u.Email)
+	if err == nil {
+		return id, ImportExisting, nil
+	}
+	if !errors.Is(err, ErrNoRecord) {
+		return 0, "", err
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hashedPassword, err := m.Passwords.Hash(base64.RawURLEncoding.EncodeToString(b))
+	if err != nil {
+		return 0, "", err
+	}
+
+	taken, err := m.taken(ctx, "users", u.ID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	result := ImportInserted
+	if taken {
+		result = ImportRenumbered
+	}
+
+	stmt := `INSERT INTO users (id, name, email, hashed_password, created, activated, role, disabled)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	id, err = m.insert(ctx, stmt, taken, u.ID, u.Name, u.Email, hashedPassword, u.Created.UTC(), u.Activated, u.Role, u.Disabled)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return id, result, nil
+}
+
+// "ImportSnippet" upisuje "snippet" sa originalnim vremenom kreiranja i isteka i vraća njegov "ID" u ovoj bazi
+// "snippet" sa istim naslovom, sadržajem i vremenom kreiranja se ne upisuje ponovo (bez obzira na "ID")
+// na taj način se prekinut uvoz može bezbjedno ponoviti
+// "snippet"-i koji su već istekli se odmah označavaju kao obrađeni, kako "webhook worker" ne bi slao "snippet.expired" događaje
+func (m *TransferModel) ImportSnippet(ctx context.Context, s Snippet) (int, string, error) {
+	var id int
+
+	stmt := `SELECT id FROM snippets WHERE created = ? AND title = ? AND content = ? LIMIT 1`
+
+	err := m.DB.QueryRowContext(ctx, stmt, s.Created.UTC(), s.Title, s.Content).Scan(&id)
+	if err == nil {
+		return id, ImportExisting, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", wrapTimeout(err)
+	}
+
+	taken, err := m.taken(ctx, "snippets", s.ID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	result := ImportInserted
+	if taken {
+		result = ImportRenumbered
+	}
+
+	stmt = `INSERT INTO snippets (id, user_id, title, content, created, expires, visibility, expiry_notified)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ? <= UTC_TIMESTAMP())`
+
+	expires := s.Expires.UTC()
+	id, err = m.insert(ctx, stmt, taken, s.ID, nullableID(s.UserID), s.Title, s.Content, s.Created.UTC(), expires, s.Visibility, expires)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return id, result, nil
+}
+
+// "taken" provjerava da li je "ID" već zauzet
+// "0" nije zauzet, ali će baza sama dodijeliti "ID"
+func (m *TransferModel) taken(ctx context.Context, table string, id int) (bool, error) {
+	if id <= 0 {
+		return false, nil
+	}
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM `+table+` WHERE id = ?)`, id).Scan(&exists)
+	return exists, wrapTimeout(err)
+}
+
+// "insert" izvršava "INSERT" čiji je prvi parametar "ID"
+// kada je "newID" = "true" (ili "ID" nije poznat), umjesto "ID"-ja se šalje "NULL", pa baza dodjeljuje novi
+func (m *TransferModel) insert(ctx context.Context, stmt string, newID bool, id int, args ...any) (int, error) {
+	if newID {
+		id = 0
+	}
+
+	result, err := m.DB.ExecContext(ctx, stmt, append([]any{nullableID(id)}, args...)...)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(lastID), nil
+}
@@ -1,37 +1,103 @@
 package models
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
+	"snippetbox.lazarmrkic.com/internal/password"
 	"strings"
 	"time"
 )
 
+// uloge korisnika - administratori imaju pristup "/admin" dijelu aplikacije
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// "Activated" označava da je korisnik potvrdio vlasništvo nad "email" adresom
+//
+//	ALTER TABLE users ADD activated BOOLEAN NOT NULL DEFAULT FALSE;
+//	UPDATE users SET activated = TRUE; -- postojeći nalozi ostaju aktivni
+//
+// "Role" i "Disabled" koriste administratori za moderaciju:
+//
+//	ALTER TABLE users ADD role VARCHAR(20) NOT NULL DEFAULT 'user';
+//	ALTER TABLE users ADD disabled BOOLEAN NOT NULL DEFAULT FALSE;
+//	UPDATE users SET role = 'admin' WHERE email = 'admin@example.com'; -- prvi administrator
+//
+// argon2id "hash" je duži od bcrypt "hash"-a, pa kolona mora biti šira od prvobitnih 60 karaktera:
+//
+//	ALTER TABLE users MODIFY hashed_password VARCHAR(255) NOT NULL;
 type User struct {
 	ID             int
 	Name           string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Activated      bool
+	TOTPEnabled    bool
+	Role           string
+	Disabled       bool
+}
+
+// "IsAdmin" se koristi i u templejtima, npr. za prikaz linka ka "/admin" stranici
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// "UserCounts" sadrži osnovnu statistiku o korisnicima za administratorsku stranicu
+type UserCounts struct {
+	Total     int
+	Activated int
+	Disabled  int
+	Admins    int
+	LastWeek  int
+}
+
+// interfejs sa metodama "UserModel"-a, od kog zavise "handler"-i
+type UserModelInterface interface {
+	Insert(ctx context.Context, name string, email string, password string) (int, error)
+	Authenticate(ctx context.Context, email string, password string) (int, error)
+	Get(ctx context.Context, id int) (User, error)
+	PasswordUpdate(ctx context.Context, id int, currentPassword string, newPassword string) error
+	GetByEmail(ctx context.Context, email string) (User, error)
+	PasswordReset(ctx context.Context, id int, newPassword string) error
+	PasswordResetWithToken(ctx context.Context, token string, newPassword string) (int, error)
+	Activate(ctx context.Context, id int) error
+	VerifyPassword(ctx context.Context, id int, password string) error
+	TOTPEnable(ctx context.Context, id int, secret string, recoveryCodes []string) error
+	TOTPDisable(ctx context.Context, id int) error
+	TOTPSecret(ctx context.Context, id int) (string, error)
+	TOTPUseStep(ctx context.Context, id int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, id int, code string) (bool, error)
+	List(ctx context.Context, limit int, offset int) ([]User, error)
+	Counts(ctx context.Context) (UserCounts, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	SetRole(ctx context.Context, id int, role string) error
+	Delete(ctx context.Context, id int) error
 }
 
 // "UserModel" struct omotava "connection pool"
 // biće proslijeđen u "handlers" kao zavisnost
+// "Passwords" određuje algoritam za nove "hash"-eve - "nulta" vrijednost koristi bcrypt sa cijenom 12
 type UserModel struct {
-	DB *sql.DB
+	DB        *sql.DB
+	Passwords password.Hasher
 }
 
-func (m *UserModel) Insert(name string, email string, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+// "Insert" vraća "ID" novog korisnika, kako bi mu se odmah mogao poslati token za aktivaciju
+func (m *UserModel) Insert(ctx context.Context, name string, email string, plaintext string) (int, error) {
+	hashedPassword, err := m.Passwords.Hash(plaintext)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := m.DB.ExecContext(ctx, stmt, name, email, hashedPassword)
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		// provjeravamo da li je tip greške "*mysql.MySQLError"
@@ -40,49 +106,375 @@ func (m *UserModel) Insert(name string, email string, password string) error {
 		// ukoliko se radi o ovom tipu greške, onda vraćamo "ErrDuplicateEmail" error
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
+				return 0, ErrDuplicateEmail
 			}
 		}
+		return 0, wrapTimeout(err)
 	}
 
-	return err
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
-func (m *UserModel) Authenticate(email string, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email string, plaintext string) (int, error) {
 	// prvo trebamo da izvadimo "mail" i "hashed_password" koji su povezani sa "email" string-om
 	var id int
-	var hashedPassword []byte
+	var hashedPassword string
+	var disabled bool
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
 		} else {
-			return 0, err
+			return 0, wrapTimeout(err)
 		}
 	}
 
 	// sada provjeravamo da li se poklapaju "hashed password" i "plain-text password"
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	needsRehash, err := m.Passwords.Verify(plaintext, hashedPassword)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if errors.Is(err, password.ErrMismatch) {
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
 		}
 	}
 
+	// "hash" je kreiran starim algoritmom ili starom cijenom, pa ga mijenjamo dok imamo "plain-text" lozinku
+	// na taj način se heširanje može pojačati bez resetovanja lozinki
+	// neuspjeh nije razlog da se odbije prijava - pokušaćemo ponovo prilikom sledeće prijave
+	if needsRehash {
+		_ = m.rehash(ctx, id, plaintext, hashedPassword)
+	}
+
+	// onemogućeni nalog prijavljujemo tek nakon provjere lozinke
+	// na taj način ne otkrivamo status naloga nekome ko ne zna lozinku
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
 	// ukoliko nema grešaka, onda vraćamo "user ID"
 	return id, nil
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+// "Get" vraća podatke o korisniku na osnovu "ID"-a
+// "hashed_password" namjerno ne vadimo iz baze, jer nije potreban za prikaz
+func (m *UserModel) Get(ctx context.Context, id int) (User, error) {
+	var user User
+
+	stmt := `SELECT id, name, email, created, activated, totp_enabled, role, disabled FROM users WHERE id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Activated, &user.TOTPEnabled, &user.Role, &user.Disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		} else {
+			return User{}, wrapTimeout(err)
+		}
+	}
+
+	return user, nil
+}
+
+// "VerifyPassword" provjerava lozinku korisnika na isti način kao i "Authenticate" metoda
+// koristi se kada već ulogovani korisnik mora ponovo da potvrdi identitet (npr. prije osjetljive izmjene naloga)
+func (m *UserModel) VerifyPassword(ctx context.Context, id int, plaintext string) error {
+	var hashedPassword string
+
+	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return wrapTimeout(err)
+		}
+	}
+
+	_, err = m.Passwords.Verify(plaintext, hashedPassword)
+	if err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// "PasswordUpdate" mijenja lozinku korisnika
+// trenutna lozinka se provjerava na isti način kao i u "Authenticate" metodi
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword string, newPassword string) error {
+	err := m.VerifyPassword(ctx, id, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	return m.PasswordReset(ctx, id, newPassword)
+}
+
+// "GetByEmail" vraća korisnika sa datom "email" adresom (npr. za "forgot password" formu)
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (User, error) {
+	var user User
+
+	stmt := `SELECT id, name, email, created, activated, totp_enabled, role, disabled FROM users WHERE email = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Activated, &user.TOTPEnabled, &user.Role, &user.Disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		} else {
+			return User{}, wrapTimeout(err)
+		}
+	}
+
+	return user, nil
+}
+
+// "PasswordReset" postavlja novu lozinku bez provjere trenutne
+// pozivalac je dužan da prethodno provjeri identitet korisnika (npr. preko jednokratnog tokena)
+func (m *UserModel) PasswordReset(ctx context.Context, id int, newPassword string) error {
+	hashedPassword, err := m.Passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+	_, err = m.DB.ExecContext(ctx, stmt, hashedPassword, id)
+	return wrapTimeout(err)
+}
+
+// "PasswordResetWithToken" postavlja novu lozinku korisniku kom pripada token za reset lozinke i vraća njegov "ID"
+// provjera tokena, izmjena lozinke i brisanje svih tokena za reset (i ranije poslatih) se izvršavaju unutar iste transakcije
+// ukoliko izmjena ne uspije, token ostaje važeći i korisnik može pokušati ponovo
+// ukoliko token ne postoji ili je istekao, vraća se "ErrNoRecord"
+func (m *UserModel) PasswordResetWithToken(ctx context.Context, token string, newPassword string) (int, error) {
+	// "hash" lozinke se računa prije transakcije, kako red sa tokenom ne bi bio zaključan dok traje bcrypt
+	hashedPassword, err := m.Passwords.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := sha256.Sum256([]byte(token))
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	var id int
+
+	stmt := `SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, stmt, hash[:], ScopePasswordReset).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, wrapTimeout(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET hashed_password = ? WHERE id = ?`, hashedPassword, id)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = ? AND user_id = ?`, ScopePasswordReset, id)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return id, nil
+}
+
+// "rehash" upisuje novi "hash" lozinke
+// uslov "hashed_password = ?" sprječava da se pregazi lozinka koja je u međuvremenu promijenjena
+func (m *UserModel) rehash(ctx context.Context, id int, plaintext string, oldHash string) error {
+	hashedPassword, err := m.Passwords.Hash(plaintext)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = ? WHERE id = ? AND hashed_password = ?"
+
+	_, err = m.DB.ExecContext(ctx, stmt, hashedPassword, id, oldHash)
+	return wrapTimeout(err)
+}
+
+// "Activate" označava da je korisnik potvrdio svoju "email" adresu
+func (m *UserModel) Activate(ctx context.Context, id int) error {
+	stmt := "UPDATE users SET activated = TRUE WHERE id = ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return wrapTimeout(err)
+}
+
+// "List" vraća korisnike od najnovijeg ka najstarijem, stranicu po stranicu
+func (m *UserModel) List(ctx context.Context, limit int, offset int) ([]User, error) {
+	stmt := `SELECT id, name, email, created, activated, totp_enabled, role, disabled FROM users
+    ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated, &u.TOTPEnabled, &u.Role, &u.Disabled)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return users, nil
+}
+
+// "Counts" vraća statistiku jednim upitom
+// "COALESCE" je potreban jer "SUM" nad praznom tabelom vraća "NULL"
+func (m *UserModel) Counts(ctx context.Context) (UserCounts, error) {
+	var c UserCounts
+
+	stmt := `SELECT COUNT(*),
+    COALESCE(SUM(activated), 0),
+    COALESCE(SUM(disabled), 0),
+    COALESCE(SUM(role = 'admin'), 0),
+    COALESCE(SUM(created > UTC_TIMESTAMP() - INTERVAL 7 DAY), 0)
+    FROM users`
+
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&c.Total, &c.Activated, &c.Disabled, &c.Admins, &c.LastWeek)
+	if err != nil {
+		return UserCounts{}, wrapTimeout(err)
+	}
+
+	return c, nil
+}
+
+// "SetDisabled" onemogućava ili ponovo omogućava nalog
+// pozivalac je dužan da nakon onemogućavanja odjavi sve sesije korisnika
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	stmt := "UPDATE users SET disabled = ? WHERE id = ?"
+
+	result, err := m.DB.ExecContext(ctx, stmt, disabled, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	// "RowsAffected" je "0" i kada se vrijednost nije promijenila, pa postojanje provjeravamo posebno
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		exists, err := m.exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// "SetRole" mijenja ulogu korisnika ("user" ili "admin")
+// nova uloga važi od sledećeg zahtjeva, jer "authenticate" učitava korisnika iz baze na svakom zahtjevu
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	stmt := "UPDATE users SET role = ? WHERE id = ?"
+
+	result, err := m.DB.ExecContext(ctx, stmt, role, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		exists, err := m.exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// "exists" provjerava postojanje reda, bez obzira na to da li je nalog onemogućen
+func (m *UserModel) exists(ctx context.Context, id int) (bool, error) {
 	var exists bool
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&exists)
+
+	return exists, wrapTimeout(err)
+}
+
+// "Delete" trajno briše nalog, zajedno sa svim sesijama i tokenima korisnika
+// "snippet"-e je potrebno prethodno obrisati preko "SnippetModel.DeleteForUser" (kako bi se poništio i keš)
+// brisanje se izvršava unutar jedne transakcije, kako ne bi ostala aktivna sesija za nalog koji ne postoji
+func (m *UserModel) Delete(ctx context.Context, id int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	// sesije iz "mysqlstore" tabele brišemo preko "user_sessions" evidencije, jer "sessions" tabela nema "user_id" kolonu
+	stmts := []string{
+		`DELETE s FROM sessions s JOIN user_sessions us ON us.token = s.token WHERE us.user_id = ?`,
+		`DELETE FROM user_sessions WHERE user_id = ?`,
+		`DELETE FROM tokens WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+	}
+
+	for _, stmt := range stmts {
+		_, err = tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return wrapTimeout(err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
 
-	return exists, err
+	return wrapTimeout(tx.Commit())
 }
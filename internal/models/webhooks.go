@@ -0,0 +1,440 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tabele za "webhook"-e, red za isporuku i evidenciju pokušaja:
+//
+//	CREATE TABLE webhooks (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    user_id INTEGER NULL,
+//	    team_id INTEGER NULL,
+//	    url VARCHAR(2048) NOT NULL,
+//	    secret VARCHAR(100) NOT NULL,
+//	    events VARCHAR(255) NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    CONSTRAINT webhooks_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+//	    CONSTRAINT webhooks_fk_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+//	);
+//
+//	CREATE TABLE webhook_deliveries (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    webhook_id INTEGER NOT NULL,
+//	    event VARCHAR(30) NOT NULL,
+//	    payload MEDIUMTEXT NOT NULL,
+//	    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+//	    attempts INTEGER NOT NULL DEFAULT 0,
+//	    next_attempt DATETIME NOT NULL,
+//	    created DATETIME NOT NULL,
+//	    CONSTRAINT webhook_deliveries_fk_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+//	);
+//	CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt);
+//
+//	CREATE TABLE webhook_attempts (
+//	    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//	    delivery_id INTEGER NOT NULL,
+//	    attempted_at DATETIME NOT NULL,
+//	    response_status INTEGER NOT NULL,
+//	    error VARCHAR(255) NOT NULL DEFAULT '',
+//	    duration_ms INTEGER NOT NULL,
+//	    CONSTRAINT webhook_attempts_fk_delivery FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+//	);
+//
+// događaj "snippet.expired" se šalje samo jednom - "expiry_notified" bilježi da je "snippet" već obrađen
+// "snippet"-i koji su istekli prije uvođenja "webhook"-a se označavaju odmah, kako se ne bi poslali svi odjednom
+//
+//	ALTER TABLE snippets ADD expiry_notified BOOLEAN NOT NULL DEFAULT FALSE;
+//	UPDATE snippets SET expiry_notified = TRUE WHERE expires <= UTC_TIMESTAMP();
+//	CREATE INDEX idx_snippets_expiry_notified ON snippets(expiry_notified, expires);
+
+// događaji na koje se "webhook" može pretplatiti
+const (
+	EventSnippetCreated = "snippet.created"
+	EventSnippetEdited  = "snippet.edited"
+	EventSnippetExpired = "snippet.expired"
+)
+
+// "WebhookEvents" sadrži sve događaje, redom kojim se prikazuju na formi
+var WebhookEvents = []string{EventSnippetCreated, EventSnippetEdited, EventSnippetExpired}
+
+// statusi isporuke
+// isporuka je "pending" dok ne uspije ili dok se ne potroše svi pokušaji
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// "Webhook" pripada ili korisniku ("UserID") ili timu ("TeamID") - drugo polje je "0"
+// "Secret" se čuva u izvornom obliku, jer je potreban za potpisivanje svakog zahtjeva
+type Webhook struct {
+	ID       int
+	UserID   int
+	TeamID   int
+	TeamName string
+	URL      string
+	Secret   string
+	Events   []string
+	Created  time.Time
+}
+
+func (w Webhook) HasEvent(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// "WebhookDelivery" je jedna isporuka događaja, zajedno sa podacima "webhook"-a potrebnim za slanje
+type WebhookDelivery struct {
+	ID          int
+	WebhookID   int
+	URL         string
+	Secret      string
+	Event       string
+	Payload     []byte
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	Created     time.Time
+}
+
+// "WebhookAttempt" je jedan pokušaj isporuke
+// "ResponseStatus" je "0" ukoliko odgovor nije ni stigao
+type WebhookAttempt struct {
+	ID             int
+	DeliveryID     int
+	Event          string
+	DeliveryStatus string
+	Attempt        int
+	AttemptedAt    time.Time
+	ResponseStatus int
+	Error          string
+	Duration       time.Duration
+}
+
+type WebhookModelInterface interface {
+	Insert(ctx context.Context, userID int, teamID int, url string, secret string, events []string) (int, error)
+	Get(ctx context.Context, id int) (Webhook, error)
+	ListForUser(ctx context.Context, userID int) ([]Webhook, error)
+	Delete(ctx context.Context, id int) error
+	Subscribers(ctx context.Context, event string, userID int, teamID int) ([]Webhook, error)
+	Enqueue(ctx context.Context, webhookID int, event string, payload []byte) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, deliveryID int, attempt WebhookAttempt, status string, nextAttempt time.Time) error
+	Attempts(ctx context.Context, webhookID int, limit int, offset int) ([]WebhookAttempt, error)
+	CountAttempts(ctx context.Context, webhookID int) (int, error)
+	TakeExpiredSnippets(ctx context.Context, limit int) ([]Snippet, error)
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// "userID" i "teamID" - tačno jedno od njih treba da bude različito od "0"
+func (m *WebhookModel) Insert(ctx context.Context, userID int, teamID int, url string, secret string, events []string) (int, error) {
+	stmt := `INSERT INTO webhooks (user_id, team_id, url, secret, events, created)
+    VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.ExecContext(ctx, stmt, nullableID(userID), nullableID(teamID), url, secret, strings.Join(events, ","))
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+const webhookColumns = `w.id, w.user_id, w.team_id, t.name, w.url, w.secret, w.events, w.created
+    FROM webhooks w LEFT JOIN teams t ON t.id = w.team_id`
+
+func scanWebhook(row rowScanner, w *Webhook) error {
+	var userID, teamID sql.NullInt64
+	var teamName sql.NullString
+	var events string
+
+	err := row.Scan(&w.ID, &userID, &teamID, &teamName, &w.URL, &w.Secret, &events, &w.Created)
+	if err != nil {
+		return err
+	}
+
+	w.UserID = int(userID.Int64)
+	w.TeamID = int(teamID.Int64)
+	w.TeamName = teamName.String
+	w.Events = strings.Split(events, ",")
+	return nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (Webhook, error) {
+	var w Webhook
+
+	err := scanWebhook(m.DB.QueryRowContext(ctx, `SELECT `+webhookColumns+` WHERE w.id = ?`, id), &w)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, ErrNoRecord
+		} else {
+			return Webhook{}, wrapTimeout(err)
+		}
+	}
+
+	return w, nil
+}
+
+// "ListForUser" vraća lične "webhook"-e korisnika i "webhook"-e timova čiji je korisnik vlasnik
+func (m *WebhookModel) ListForUser(ctx context.Context, userID int) ([]Webhook, error) {
+	stmt := `SELECT ` + webhookColumns + ` WHERE w.user_id = ?
+    OR w.team_id IN (SELECT team_id FROM team_members WHERE user_id = ? AND role = 'owner')
+    ORDER BY w.id DESC`
+
+	return m.query(ctx, stmt, userID, userID)
+}
+
+func (m *WebhookModel) query(ctx context.Context, stmt string, args ...any) ([]Webhook, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var w Webhook
+		err = scanWebhook(rows, &w)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return webhooks, nil
+}
+
+// "Delete" briše "webhook" zajedno sa isporukama i pokušajima ("ON DELETE CASCADE")
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// "Subscribers" vraća "webhook"-e korisnika i tima koji su pretplaćeni na dati događaj
+// "0" za "userID" ili "teamID" se ne poklapa ni sa jednim redom
+func (m *WebhookModel) Subscribers(ctx context.Context, event string, userID int, teamID int) ([]Webhook, error) {
+	stmt := `SELECT ` + webhookColumns + ` WHERE (w.user_id = ? OR w.team_id = ?) AND FIND_IN_SET(?, w.events) > 0`
+
+	return m.query(ctx, stmt, userID, teamID, event)
+}
+
+// "Enqueue" dodaje isporuku u red - "worker" je šalje pri sledećem prolazu
+func (m *WebhookModel) Enqueue(ctx context.Context, webhookID int, event string, payload []byte) error {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt, created)
+    VALUES (?, ?, ?, 'pending', 0, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	_, err := m.DB.ExecContext(ctx, stmt, webhookID, event, payload)
+	return wrapTimeout(err)
+}
+
+// "ClaimDue" preuzima isporuke kojima je došlo vrijeme za slanje
+// preuzetim isporukama se "next_attempt" pomjera za "lease", pa ih drugi proces (ili sledeći prolaz) neće preuzeti
+// ukoliko se proces ugasi usred slanja, isporuka se ponovo šalje nakon isteka "lease"-a
+// "SKIP LOCKED" omogućava da više instanci aplikacije radi istovremeno, bez čekanja jedna na drugu
+func (m *WebhookModel) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.created
+    FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status = 'pending' AND d.next_attempt <= UTC_TIMESTAMP()
+    ORDER BY d.next_attempt LIMIT ? FOR UPDATE OF d SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt, &d.Created)
+		if err != nil {
+			rows.Close()
+			return nil, wrapTimeout(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	for _, d := range deliveries {
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt = ? WHERE id = ?`, time.Now().Add(lease).UTC(), d.ID)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return deliveries, nil
+}
+
+// "RecordAttempt" upisuje pokušaj isporuke i ažurira status isporuke
+// "nextAttempt" se koristi samo dok je isporuka "pending"
+func (m *WebhookModel) RecordAttempt(ctx context.Context, deliveryID int, attempt WebhookAttempt, status string, nextAttempt time.Time) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	// poruka o grešci se skraćuje na veličinu kolone
+	errorText := attempt.Error
+	if len(errorText) > 255 {
+		errorText = errorText[:255]
+	}
+
+	stmt := `INSERT INTO webhook_attempts (delivery_id, attempted_at, response_status, error, duration_ms)
+    VALUES (?, ?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, stmt, deliveryID, attempt.AttemptedAt.UTC(), attempt.ResponseStatus, errorText, attempt.Duration.Milliseconds())
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	stmt = `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, next_attempt = ? WHERE id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, status, nextAttempt.UTC(), deliveryID)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	return wrapTimeout(tx.Commit())
+}
+
+// "Attempts" vraća pokušaje isporuke za "webhook", od najnovijeg ka najstarijem (za stranicu sa evidencijom)
+// "Attempt" je redni broj pokušaja u okviru iste isporuke
+func (m *WebhookModel) Attempts(ctx context.Context, webhookID int, limit int, offset int) ([]WebhookAttempt, error) {
+	stmt := `SELECT a.id, a.delivery_id, d.event, d.status,
+    (SELECT COUNT(*) FROM webhook_attempts p WHERE p.delivery_id = a.delivery_id AND p.id <= a.id),
+    a.attempted_at, a.response_status, a.error, a.duration_ms
+    FROM webhook_attempts a JOIN webhook_deliveries d ON d.id = a.delivery_id
+    WHERE d.webhook_id = ? ORDER BY a.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, webhookID, limit, offset)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer rows.Close()
+
+	var attempts []WebhookAttempt
+	for rows.Next() {
+		var a WebhookAttempt
+		var durationMS int64
+		err = rows.Scan(&a.ID, &a.DeliveryID, &a.Event, &a.DeliveryStatus, &a.Attempt, &a.AttemptedAt, &a.ResponseStatus, &a.Error, &durationMS)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+		a.Duration = time.Duration(durationMS) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return attempts, nil
+}
+
+func (m *WebhookModel) CountAttempts(ctx context.Context, webhookID int) (int, error) {
+	var count int
+
+	stmt := `SELECT COUNT(*) FROM webhook_attempts a JOIN webhook_deliveries d ON d.id = a.delivery_id WHERE d.webhook_id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, webhookID).Scan(&count)
+	if err != nil {
+		return 0, wrapTimeout(err)
+	}
+
+	return count, nil
+}
+
+// "TakeExpiredSnippets" vraća "snippet"-e koji su istekli, a za koje još nije poslat "snippet.expired" događaj
+// vraćeni "snippet"-i se odmah označavaju, pa se događaj šalje najviše jednom
+func (m *WebhookModel) TakeExpiredSnippets(ctx context.Context, limit int) ([]Snippet, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expiry_notified = FALSE AND s.expires <= UTC_TIMESTAMP()
+    ORDER BY s.expires LIMIT ? FOR UPDATE OF s SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	var snippets []Snippet
+	for rows.Next() {
+		var s Snippet
+		err = scanSnippet(rows, &s)
+		if err != nil {
+			rows.Close()
+			return nil, wrapTimeout(err)
+		}
+		snippets = append(snippets, s)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	for _, s := range snippets {
+		_, err = tx.ExecContext(ctx, `UPDATE snippets SET expiry_notified = TRUE WHERE id = ?`, s.ID)
+		if err != nil {
+			return nil, wrapTimeout(err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	return snippets, nil
+}
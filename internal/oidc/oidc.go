@@ -0,0 +1,218 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// paket implementira "authorization code" tok iz OpenID Connect specifikacije, uz PKCE (RFC 7636)
+// podržan je samo RS256 potpis "ID token"-a, koji koriste svi veći "identity provider"-i
+
+var (
+	ErrInvalidToken = errors.New("oidc: invalid ID token")
+	ErrExchange     = errors.New("oidc: code exchange failed")
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// "HTTPClient" je opcion - ukoliko nije postavljen, koristi se klijent sa "timeout"-om od 10 sekundi
+	HTTPClient *http.Client
+}
+
+// "metadata" su polja iz "/.well-known/openid-configuration" dokumenta koja nam trebaju
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// "Claims" su podaci o korisniku iz "ID token"-a
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+	Expiry        time.Time
+}
+
+type Provider struct {
+	config   Config
+	client   *http.Client
+	metadata metadata
+
+	// javni ključevi "provider"-a se keširaju
+	// kada stigne token sa nepoznatim "kid"-om, ključevi se ponovo učitavaju (rotacija ključeva)
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+
+	// "now" postoji kako bi testovi mogli da kontrolišu vrijeme
+	now func() time.Time
+}
+
+// "Discover" učitava konfiguraciju "provider"-a sa "/.well-known/openid-configuration" adrese
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &Provider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	err := p.getJSON(ctx, wellKnown, &p.metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+
+	// "issuer" iz dokumenta mora biti identičan onom koji je podešen (OpenID Connect Discovery, sekcija 4.3)
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer mismatch: got %q, want %q", p.metadata.Issuer, config.Issuer)
+	}
+
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing required endpoints")
+	}
+
+	return p, nil
+}
+
+// "NewPKCE" generiše "code verifier" i odgovarajući S256 "code challenge"
+// "verifier" se čuva u sesiji, a "challenge" se šalje "provider"-u u linku za prijavu
+func NewPKCE() (verifier string, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// "RandomString" vraća nasumičnu vrijednost za "state" i "nonce" parametre
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// "AuthCodeURL" vraća adresu na koju se korisnik preusmjerava radi prijave kod "provider"-a
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientID)
+	v.Set("redirect_uri", p.config.RedirectURL)
+	v.Set("scope", "openid email profile")
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.metadata.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// "Exchange" mijenja "authorization code" za tokene i provjerava "ID token"
+// provjerava se potpis, "issuer", "audience", rok trajanja i "nonce"
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// "client_secret_basic" autentifikacija (RFC 6749, sekcija 2.3.1)
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return Claims{}, fmt.Errorf("%w: %s %s", ErrExchange, body.Error, body.ErrorDescription)
+	}
+
+	claims, err := p.verify(ctx, body.IDToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
@@ -0,0 +1,192 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+	"time"
+)
+
+// "mockProvider" je lokalni OIDC "provider" za testove
+// izdaje "ID token" potpisan RSA ključem za bilo koji "code", ukoliko se "code_verifier" poklapa sa "code_challenge"-om
+type mockProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	claims    map[string]any
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "snippetbox" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, m.claims),
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) sign(t *testing.T, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	m := newMockProvider(t)
+
+	p, err := Discover(ctx, Config{
+		Issuer:       m.server.URL,
+		ClientID:     "snippetbox",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://localhost:4000/user/login/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":            m.server.URL,
+			"sub":            "user-1",
+			"aud":            "snippetbox",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          "nonce-1",
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"name":           "Alice",
+		}
+	}
+
+	t.Run("Auth code URL", func(t *testing.T) {
+		u, err := url.Parse(p.AuthCodeURL("state-1", "nonce-1", "challenge"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := u.Query()
+		assert.Equal(t, u.Path, "/authorize")
+		assert.Equal(t, q.Get("state"), "state-1")
+		assert.Equal(t, q.Get("nonce"), "nonce-1")
+		assert.Equal(t, q.Get("code_challenge_method"), "S256")
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		verifier, challenge, err := NewPKCE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.challenge = challenge
+		m.claims = validClaims()
+
+		claims, err := p.Exchange(ctx, "code", verifier, "nonce-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, claims.Subject, "user-1")
+		assert.Equal(t, claims.Email, "alice@example.com")
+		assert.Equal(t, claims.EmailVerified, true)
+		assert.Equal(t, claims.Name, "Alice")
+	})
+
+	t.Run("Wrong verifier", func(t *testing.T) {
+		_, challenge, _ := NewPKCE()
+		m.challenge = challenge
+		m.claims = validClaims()
+
+		_, err := p.Exchange(ctx, "code", "wrong", "nonce-1")
+		assert.Equal(t, errors.Is(err, ErrExchange), true)
+	})
+
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+		nonce  string
+	}{
+		{name: "Wrong nonce", modify: func(c map[string]any) {}, nonce: "other"},
+		{name: "Wrong audience", modify: func(c map[string]any) { c["aud"] = "someone-else" }, nonce: "nonce-1"},
+		{name: "Wrong issuer", modify: func(c map[string]any) { c["iss"] = "https://evil.example" }, nonce: "nonce-1"},
+		{name: "Expired", modify: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, nonce: "nonce-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, challenge, _ := NewPKCE()
+			m.challenge = challenge
+			m.claims = validClaims()
+			tt.modify(m.claims)
+
+			_, err := p.Exchange(ctx, "code", verifier, tt.nonce)
+			assert.Equal(t, errors.Is(err, ErrInvalidToken), true)
+		})
+	}
+}
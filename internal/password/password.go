@@ -0,0 +1,219 @@
+// "password" paket hešira lozinke i provjerava ih
+// podržani su bcrypt i argon2id, a algoritam se prepoznaje iz samog "hash"-a
+// na taj način se algoritam ili cijena mogu promijeniti bez resetovanja postojećih lozinki
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// podržani algoritmi
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// "DefaultBcryptCost" je cijena koja se koristila prije nego što je postala podesiva
+const DefaultBcryptCost = 12
+
+var (
+	// "ErrMismatch" se vraća kada lozinka ne odgovara "hash"-u
+	ErrMismatch = errors.New("password: hash and password do not match")
+	// "ErrInvalidHash" se vraća kada "hash" nije u poznatom formatu
+	ErrInvalidHash = errors.New("password: invalid encoded hash")
+	// "ErrIncompatibleVersion" se vraća za argon2 "hash" sa nepodržanom verzijom algoritma
+	ErrIncompatibleVersion = errors.New("password: incompatible argon2 version")
+)
+
+// "Argon2Params" su parametri za argon2id
+// "Memory" je izražena u KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// "DefaultArgon2Params" prate preporuke iz RFC 9106 za sisteme sa ograničenom memorijom
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// "Hasher" hešira nove lozinke podešenim algoritmom
+// "nulta" vrijednost koristi bcrypt sa cijenom "DefaultBcryptCost"
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// "Validate" provjerava podešavanja - poziva se pri pokretanju aplikacije
+func (h Hasher) Validate() error {
+	switch h.algorithm() {
+	case Bcrypt:
+		cost := h.bcryptCost()
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return fmt.Errorf("password: bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		p := h.argon2Params()
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 {
+			return errors.New("password: invalid argon2id parameters")
+		}
+	default:
+		return fmt.Errorf("password: unknown algorithm %q", h.Algorithm)
+	}
+
+	return nil
+}
+
+// "Hash" vraća "hash" lozinke u tekstualnom obliku
+// bcrypt "hash" ima standardni "$2a$..." format, a argon2id koristi PHC format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (h Hasher) Hash(plaintext string) (string, error) {
+	switch h.algorithm() {
+	case Argon2id:
+		p := h.argon2Params()
+
+		salt := make([]byte, p.SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(plaintext), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), h.bcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+}
+
+// "Verify" provjerava lozinku i vraća "needsRehash" = "true" ukoliko je "hash" kreiran
+// drugim algoritmom ili drugim parametrima od trenutno podešenih
+// ukoliko lozinka nije ispravna, vraća se "ErrMismatch"
+func (h Hasher) Verify(plaintext string, encoded string) (needsRehash bool, err error) {
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(plaintext), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, ErrMismatch
+		}
+
+		want := h.argon2Params()
+		needsRehash = h.algorithm() != Argon2id ||
+			p.Memory != want.Memory || p.Iterations != want.Iterations || p.Parallelism != want.Parallelism ||
+			uint32(len(salt)) != want.SaltLength || p.KeyLength != want.KeyLength
+		return needsRehash, nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plaintext))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, ErrMismatch
+		}
+		return false, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+
+	return h.algorithm() != Bcrypt || cost != h.bcryptCost(), nil
+}
+
+func (h Hasher) algorithm() string {
+	if h.Algorithm == "" {
+		return Bcrypt
+	}
+	return h.Algorithm
+}
+
+func (h Hasher) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return DefaultBcryptCost
+	}
+	return h.BcryptCost
+}
+
+// parametri koji nisu postavljeni dobijaju podrazumijevane vrijednosti
+func (h Hasher) argon2Params() Argon2Params {
+	p := h.Argon2
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2Params.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultArgon2Params.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return p
+}
+
+// "decodeArgon2" čita parametre, "salt" i ključ iz PHC formata
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	// "$argon2id$v=19$m=...,t=...,p=...$salt$key" - prvi dio je prazan string prije prvog "$"
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrIncompatibleVersion
+	}
+
+	var p Argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil || p.Iterations == 0 || p.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
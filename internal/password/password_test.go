@@ -0,0 +1,97 @@
+package password
+
+import (
+	"errors"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"strings"
+	"testing"
+)
+
+// mali parametri, kako bi testovi bili brzi
+var (
+	fastBcrypt = Hasher{Algorithm: Bcrypt, BcryptCost: 4}
+	fastArgon2 = Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}}
+)
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{name: "Bcrypt", hasher: fastBcrypt, prefix: "$2a$04$"},
+		{name: "Argon2id", hasher: fastArgon2, prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("pa$$word")
+			assert.NilError(t, err)
+			assert.Equal(t, strings.HasPrefix(hash, tt.prefix), true)
+
+			needsRehash, err := tt.hasher.Verify("pa$$word", hash)
+			assert.NilError(t, err)
+			assert.Equal(t, needsRehash, false)
+
+			_, err = tt.hasher.Verify("wrong", hash)
+			assert.Equal(t, errors.Is(err, ErrMismatch), true)
+		})
+	}
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	bcryptHash, err := fastBcrypt.Hash("pa$$word")
+	assert.NilError(t, err)
+
+	argon2Hash, err := fastArgon2.Hash("pa$$word")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		want   bool
+	}{
+		{name: "Same bcrypt cost", hasher: fastBcrypt, hash: bcryptHash, want: false},
+		{name: "Higher bcrypt cost", hasher: Hasher{Algorithm: Bcrypt, BcryptCost: 5}, hash: bcryptHash, want: true},
+		{name: "Bcrypt to argon2id", hasher: fastArgon2, hash: bcryptHash, want: true},
+		{name: "Argon2id to bcrypt", hasher: fastBcrypt, hash: argon2Hash, want: true},
+		{name: "More argon2id memory", hasher: Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1}}, hash: argon2Hash, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash, err := tt.hasher.Verify("pa$$word", tt.hash)
+			assert.NilError(t, err)
+			assert.Equal(t, needsRehash, tt.want)
+		})
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want error
+	}{
+		{name: "Empty", hash: "", want: ErrInvalidHash},
+		{name: "Garbage", hash: "not-a-hash", want: ErrInvalidHash},
+		{name: "Missing argon2 key", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ", want: ErrInvalidHash},
+		{name: "Bad argon2 params", hash: "$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5", want: ErrInvalidHash},
+		{name: "Unknown argon2 version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5", want: ErrIncompatibleVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fastBcrypt.Verify("pa$$word", tt.hash)
+			assert.Equal(t, errors.Is(err, tt.want), true)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NilError(t, Hasher{}.Validate())
+	assert.NilError(t, fastArgon2.Validate())
+	assert.Equal(t, Hasher{Algorithm: "md5"}.Validate() != nil, true)
+	assert.Equal(t, Hasher{BcryptCost: 40}.Validate() != nil, true)
+}
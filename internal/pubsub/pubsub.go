@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+)
+
+// paket "pubsub" prosljeđuje događaje svim pretplatnicima unutar istog procesa (npr. otvorenim "/events" konekcijama)
+// događaji se ne čuvaju - pretplatnik dobija samo događaje objavljene dok je pretplaćen
+
+// "ErrTooManySubscribers" se vraća kada je dostignut maksimalan broj pretplatnika
+var ErrTooManySubscribers = errors.New("pubsub: too many subscribers")
+
+// "Message" je jedan događaj
+// "Data" je već serijalizovan, kako se ne bi serijalizovao posebno za svakog pretplatnika
+type Message struct {
+	Event string
+	Data  []byte
+}
+
+// "Broker" šalje poruke pretplatnicima bez čekanja
+// svaki pretplatnik ima "buffer" od "bufferSize" poruka - ukoliko je pun (spor klijent), poruka se za njega preskače
+// na taj način jedan spor pretplatnik ne može da uspori objavljivanje (npr. "handler" koji kreira "snippet")
+type Broker struct {
+	mu             sync.Mutex
+	subscribers    map[chan Message]struct{}
+	bufferSize     int
+	maxSubscribers int
+}
+
+// "maxSubscribers" <= 0 znači da broj pretplatnika nije ograničen
+func NewBroker(maxSubscribers int, bufferSize int) *Broker {
+	return &Broker{
+		subscribers:    make(map[chan Message]struct{}),
+		bufferSize:     bufferSize,
+		maxSubscribers: maxSubscribers,
+	}
+}
+
+// "Subscribe" vraća kanal sa porukama i funkciju za odjavu
+// nakon odjave se kanal zatvara - odjava se može pozvati više puta
+func (b *Broker) Subscribe() (<-chan Message, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxSubscribers > 0 && len(b.subscribers) >= b.maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	ch := make(chan Message, b.bufferSize)
+	b.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe, nil
+}
+
+// "Publish" šalje poruku svim pretplatnicima i vraća broj pretplatnika koji je nisu primili (pun "buffer")
+func (b *Broker) Publish(msg Message) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var dropped int
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// "Len" vraća trenutni broj pretplatnika
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+	msg := Message{Event: "snippet.created", Data: []byte(`{"id":1}`)}
+
+	t.Run("Publish to all subscribers", func(t *testing.T) {
+		b := NewBroker(0, 1)
+
+		first, unsubscribeFirst, err := b.Subscribe()
+		assert.NilError(t, err)
+		defer unsubscribeFirst()
+		second, unsubscribeSecond, err := b.Subscribe()
+		assert.NilError(t, err)
+		defer unsubscribeSecond()
+
+		assert.Equal(t, b.Publish(msg), 0)
+		assert.Equal(t, string((<-first).Data), `{"id":1}`)
+		assert.Equal(t, (<-second).Event, "snippet.created")
+	})
+
+	t.Run("Slow subscribers are skipped", func(t *testing.T) {
+		b := NewBroker(0, 1)
+
+		ch, unsubscribe, err := b.Subscribe()
+		assert.NilError(t, err)
+		defer unsubscribe()
+
+		assert.Equal(t, b.Publish(msg), 0)
+		assert.Equal(t, b.Publish(msg), 1)
+		assert.Equal(t, len(ch), 1)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		b := NewBroker(0, 1)
+
+		ch, unsubscribe, err := b.Subscribe()
+		assert.NilError(t, err)
+		assert.Equal(t, b.Len(), 1)
+
+		unsubscribe()
+		unsubscribe()
+		assert.Equal(t, b.Len(), 0)
+
+		_, ok := <-ch
+		assert.Equal(t, ok, false)
+
+		// objavljivanje bez pretplatnika nije greška
+		assert.Equal(t, b.Publish(msg), 0)
+	})
+
+	t.Run("Subscriber limit", func(t *testing.T) {
+		b := NewBroker(1, 1)
+
+		_, unsubscribe, err := b.Subscribe()
+		assert.NilError(t, err)
+
+		_, _, err = b.Subscribe()
+		assert.Equal(t, err, ErrTooManySubscribers)
+
+		unsubscribe()
+		_, _, err = b.Subscribe()
+		assert.NilError(t, err)
+	})
+}
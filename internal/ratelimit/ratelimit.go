@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// paket "ratelimit" ograničava broj zahtjeva po klijentu, po "token bucket" algoritmu
+// svaki ključ (npr. IP adresa ili "ID" korisnika) ima svoju "kantu" sa najviše "Burst" tokena
+// svaki zahtjev troši jedan token, a kanta se puni brzinom od "Rate" tokena u sekundi
+// na taj način klijent može da pošalje "Burst" zahtjeva odjednom, a nakon toga u prosjeku "Rate" zahtjeva u sekundi
+
+// kante se pregledaju najviše jednom u toku "sweepInterval" perioda
+const sweepInterval = time.Minute
+
+// "Policy" određuje brzinu punjenja i veličinu kante
+// "Rate" <= 0 isključuje ograničenje
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// "Limiter" čuva kante u memoriji procesa - kao i "throttle.MemoryStore", ograničenje važi po serveru
+type Limiter struct {
+	mu        sync.Mutex
+	policy    Policy
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func New(policy Policy) *Limiter {
+	// kanta mora da primi bar jedan token, inače nijedan zahtjev ne bi prošao
+	policy.Burst = max(policy.Burst, 1)
+
+	return &Limiter{
+		policy:  policy,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// "Allow" troši jedan token iz kante za dati ključ
+// ukoliko je kanta prazna, vraća "false" i vrijeme za koje će se pojaviti naredni token
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if l.policy.Rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.policy.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// kanta se puni za vrijeme koje je prošlo od prethodnog zahtjeva
+	elapsed := max(now.Sub(b.last), 0)
+	b.tokens = math.Min(float64(l.policy.Burst), b.tokens+elapsed.Seconds()*l.policy.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.policy.Rate * float64(time.Second))
+	return false, wait
+}
+
+// "Len" vraća broj kanti koje se trenutno čuvaju
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// "sweep" briše kante koje su se u međuvremenu napunile
+// puna kanta je isto što i kanta koja ne postoji, pa brisanje ne mijenja ograničenje
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	idle := time.Duration(float64(l.policy.Burst) / l.policy.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
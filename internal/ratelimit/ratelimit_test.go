@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2023, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	t.Run("Burst and refill", func(t *testing.T) {
+		l := New(Policy{Rate: 2, Burst: 3})
+
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("10.0.0.1", now)
+			assert.Equal(t, ok, true)
+		}
+
+		ok, wait := l.Allow("10.0.0.1", now)
+		assert.Equal(t, ok, false)
+		assert.Equal(t, wait, 500*time.Millisecond)
+
+		// drugi ključ ima svoju kantu
+		ok, _ = l.Allow("user:1", now)
+		assert.Equal(t, ok, true)
+
+		ok, _ = l.Allow("10.0.0.1", now.Add(500*time.Millisecond))
+		assert.Equal(t, ok, true)
+
+		ok, wait = l.Allow("10.0.0.1", now.Add(500*time.Millisecond))
+		assert.Equal(t, ok, false)
+		assert.Equal(t, wait, 500*time.Millisecond)
+	})
+
+	t.Run("Refill is capped at the burst", func(t *testing.T) {
+		l := New(Policy{Rate: 1, Burst: 2})
+
+		l.Allow("10.0.0.1", now)
+		l.Allow("10.0.0.1", now)
+
+		later := now.Add(time.Hour)
+		for _, want := range []bool{true, true, false} {
+			ok, _ := l.Allow("10.0.0.1", later)
+			assert.Equal(t, ok, want)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		l := New(Policy{Rate: 0, Burst: 1})
+
+		for i := 0; i < 100; i++ {
+			ok, _ := l.Allow("10.0.0.1", now)
+			assert.Equal(t, ok, true)
+		}
+		assert.Equal(t, l.Len(), 0)
+	})
+
+	t.Run("Idle buckets are evicted", func(t *testing.T) {
+		l := New(Policy{Rate: 1, Burst: 10})
+
+		l.Allow("10.0.0.1", now)
+		l.Allow("10.0.0.2", now.Add(55*time.Second))
+		assert.Equal(t, l.Len(), 2)
+
+		// nakon "sweepInterval" perioda, prva kanta je puna (10 tokena za 10 sekundi), a druga još nije
+		l.Allow("10.0.0.3", now.Add(time.Minute+time.Second))
+		assert.Equal(t, l.Len(), 2)
+
+		ok, _ := l.Allow("10.0.0.2", now.Add(time.Minute+time.Second))
+		assert.Equal(t, ok, true)
+	})
+}
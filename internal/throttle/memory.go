@@ -0,0 +1,71 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// "MemoryStore" čuva brojače u memoriji procesa
+// unosi koji nisu mijenjani duže od "ttl" se povremeno brišu, kako mapa ne bi rasla neograničeno
+type MemoryStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	records   map[string]Record
+	lastSweep time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		records: make(map[string]Record),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	rec := s.records[key]
+	// stari neuspješni pokušaji se "zaboravljaju" nakon "ttl"
+	if now.Sub(rec.Last) > s.ttl {
+		rec = Record{}
+	}
+
+	rec.Failures++
+	rec.Last = now
+	s.records[key] = rec
+
+	return rec, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// "sweep" se poziva unutar "Fail" metode, najviše jednom u toku "ttl" perioda
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+
+	for key, rec := range s.records {
+		if now.Sub(rec.Last) > s.ttl {
+			delete(s.records, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,100 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// "Record" sadrži broj uzastopnih neuspješnih pokušaja za jedan ključ (npr. "email" adresu ili IP adresu)
+type Record struct {
+	Failures int
+	Last     time.Time
+}
+
+// "Store" čuva brojače neuspješnih pokušaja
+// za sada postoji samo "in-process" implementacija, ali interfejs omogućava da se brojači kasnije premjeste
+// u neki dijeljeni "store" (npr. MySQL ili Redis), kada aplikacija bude radila na više servera
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Fail(ctx context.Context, key string, now time.Time) (Record, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// "Policy" određuje koliko dugo se čeka nakon neuspješnih pokušaja
+// prvih "FreeAttempts" pokušaja nema čekanja, a nakon toga se čekanje duplira sa svakim narednim pokušajem
+// (počevši od "BaseDelay", a najviše "MaxDelay")
+// nakon "LockoutAfter" pokušaja, ključ se zaključava na "LockoutDuration"
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// "Limiter" primjenjuje "Policy" nad brojačima iz "Store"-a
+type Limiter struct {
+	store  Store
+	policy Policy
+}
+
+func New(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy}
+}
+
+// "RetryAfter" vraća koliko još treba čekati prije narednog pokušaja za dati ključ
+// "0" znači da je pokušaj dozvoljen
+func (l *Limiter) RetryAfter(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	rec, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := l.delay(rec.Failures) - now.Sub(rec.Last)
+	if wait < 0 {
+		return 0, nil
+	}
+
+	return wait, nil
+}
+
+// "Fail" bilježi neuspješan pokušaj
+// vraća "true" ukoliko je upravo ovaj pokušaj doveo do zaključavanja, kako bi pozivalac mogao da ga zabilježi u logu
+func (l *Limiter) Fail(ctx context.Context, key string, now time.Time) (bool, error) {
+	rec, err := l.store.Fail(ctx, key, now)
+	if err != nil {
+		return false, err
+	}
+
+	return rec.Failures == l.policy.LockoutAfter, nil
+}
+
+// "Reset" briše brojač nakon uspješnog pokušaja
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+// "LockoutDuration" vraća trajanje zaključavanja (npr. za poruku u logu)
+func (l *Limiter) LockoutDuration() time.Duration {
+	return l.policy.LockoutDuration
+}
+
+// "delay" vraća ukupno čekanje nakon "failures" neuspješnih pokušaja, računajući od posljednjeg pokušaja
+func (l *Limiter) delay(failures int) time.Duration {
+	p := l.policy
+
+	if failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+
+	if failures < p.FreeAttempts {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.FreeAttempts; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+
+	return min(d, p.MaxDelay)
+}
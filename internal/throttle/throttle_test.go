@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"context"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	policy := Policy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		LockoutAfter:    6,
+		LockoutDuration: time.Hour,
+	}
+
+	t.Run("Exponential backoff", func(t *testing.T) {
+		l := New(NewMemoryStore(24*time.Hour), policy)
+
+		want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+		for i, w := range want {
+			_, err := l.Fail(ctx, "alice@example.com", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wait, err := l.RetryAfter(ctx, "alice@example.com", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait != w {
+				t.Errorf("after %d failures: got %v; want %v", i+1, wait, w)
+			}
+		}
+	})
+
+	t.Run("Lockout", func(t *testing.T) {
+		l := New(NewMemoryStore(24*time.Hour), policy)
+
+		var locked bool
+		for i := 0; i < policy.LockoutAfter; i++ {
+			var err error
+			locked, err = l.Fail(ctx, "10.0.0.1", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.Equal(t, locked, true)
+
+		wait, _ := l.RetryAfter(ctx, "10.0.0.1", now.Add(time.Minute))
+		assert.Equal(t, wait, 59*time.Minute)
+
+		wait, _ = l.RetryAfter(ctx, "10.0.0.1", now.Add(time.Hour))
+		assert.Equal(t, wait, time.Duration(0))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		l := New(NewMemoryStore(24*time.Hour), policy)
+
+		for i := 0; i < 4; i++ {
+			l.Fail(ctx, "bob@example.com", now)
+		}
+		l.Reset(ctx, "bob@example.com")
+
+		wait, _ := l.RetryAfter(ctx, "bob@example.com", now)
+		assert.Equal(t, wait, time.Duration(0))
+	})
+}
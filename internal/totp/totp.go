@@ -0,0 +1,145 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// implementacija RFC 6238 (TOTP), sa parametrima koje podržavaju sve "authenticator" aplikacije:
+// HMAC-SHA1, kod od 6 cifara i period od 30 sekundi
+const (
+	Digits = 6
+	// 10^Digits
+	modulo = 1_000_000
+	Period = 30 * time.Second
+	// prihvatamo i kod iz prethodnog i narednog perioda, zbog razlike u satovima između servera i telefona
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// "GenerateSecret" vraća nasumični tajni ključ od 160 bita, kodiran u "base32" formatu
+// u tom obliku ga korisnik može i ručno unijeti u aplikaciju
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// "URI" vraća "otpauth://" adresu, koju "authenticator" aplikacije čitaju iz QR koda
+// format je opisan na https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+
+	return u.String()
+}
+
+// "Step" vraća redni broj 30-sekundnog perioda za dato vrijeme
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// "Code" računa kod za dati tajni ključ i vrijeme
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, Step(t)), nil
+}
+
+// "Validate" provjerava kod i vraća period kom on pripada
+// pozivalac treba da zapamti period, kako isti kod ne bi mogao da se iskoristi dva puta
+func Validate(secret, passcode string, t time.Time) (int64, bool) {
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != Digits {
+		return 0, false
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := Step(t)
+	for i := -skew; i <= skew; i++ {
+		step := current + int64(i)
+		// poređenje u konstantnom vremenu, kako se kod ne bi mogao pogađati mjerenjem vremena odgovora
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(passcode)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// "GenerateRecoveryCodes" vraća "n" jednokratnih kodova za slučaj da korisnik izgubi telefon
+// kodovi su u formatu "xxxxx-xxxxx", kako bi se lakše prepisivali
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+
+	for i := range codes {
+		b := make([]byte, 7)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		s := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+
+	return codes, nil
+}
+
+// "NormalizeRecoveryCode" uklanja razmake i crtice, kako bi "hash" bio isti bez obzira na način unosa
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.Join(strings.Fields(code), "")
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// algoritam iz RFC 4226 (HOTP), gdje je brojač redni broj perioda
+func code(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// "dynamic truncation" - zadnja 4 bita određuju poziciju od koje se uzimaju 4 bajta
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"encoding/base32"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"testing"
+	"time"
+)
+
+// vrijednosti iz "Appendix B" dokumenta RFC 6238 (SHA1)
+// RFC navodi kodove od 8 cifara, pa poredimo samo zadnjih 6
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, code, tt.want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Current period", func(t *testing.T) {
+		step, ok := Validate(secret, code, now)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, step, Step(now))
+	})
+
+	t.Run("Previous period", func(t *testing.T) {
+		_, ok := Validate(secret, code, now.Add(Period))
+		assert.Equal(t, ok, true)
+	})
+
+	t.Run("Too old", func(t *testing.T) {
+		_, ok := Validate(secret, code, now.Add(2*Period))
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Wrong length", func(t *testing.T) {
+		_, ok := Validate(secret, code[:5], now)
+		assert.Equal(t, ok, false)
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(codes), 10)
+	assert.Equal(t, len(codes[0]), 11)
+	assert.Equal(t, NormalizeRecoveryCode(" ABCDE-fghij "), "abcdefghij")
+}
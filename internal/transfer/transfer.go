@@ -0,0 +1,319 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"time"
+)
+
+// paket "transfer" izvozi i uvozi "snippet"-e (i opciono korisnike) u JSON Lines formatu - jedan JSON objekat po redu
+// prvi red je zaglavlje, nakon njega slijede korisnici, pa "snippet"-i:
+//
+//	{"type":"header","version":1,"exported":"2024-01-02T15:04:05Z","users":true}
+//	{"type":"user","id":1,"name":"Alice","email":"alice@example.com","created":"...","activated":true,"role":"user","disabled":false}
+//	{"type":"snippet","id":1,"title":"O snail","content":"...","created":"...","expires":"...","visibility":"public","author":{"id":1,"email":"alice@example.com"}}
+//
+// "hash"-evi lozinki, TOTP podaci, sesije i tokeni se nikad ne izvoze
+// timovi se ne prenose - "snippet"-i tima se uvoze kao privatni "snippet"-i autora
+
+// verzija formata - uvoz odbija fajlove novije verzije
+const Version = 1
+
+// tipovi zapisa
+const (
+	typeHeader  = "header"
+	typeUser    = "user"
+	typeSnippet = "snippet"
+)
+
+// napredak se loguje nakon svakih "progressEvery" zapisa
+const progressEvery = 1000
+
+// "Options" određuje šta se izvozi
+type Options struct {
+	IncludeUsers bool
+}
+
+// "ExportStats" sadrži broj izvezenih zapisa
+type ExportStats struct {
+	Users    int
+	Snippets int
+}
+
+// "ImportStats" sadrži broj uvezenih zapisa po ishodu (vidjeti "models.ImportInserted" i ostale konstante)
+// "UnknownAuthors" je broj "snippet"-a čiji autor ne postoji u ovoj bazi - uvoze se bez autora
+type ImportStats struct {
+	Users          map[string]int
+	Snippets       map[string]int
+	UnknownAuthors int
+}
+
+// zapisi koje upisuje "Export" - "Type" je uvijek prvo polje u redu
+type headerRecord struct {
+	Type     string    `json:"type"`
+	Version  int       `json:"version"`
+	Exported time.Time `json:"exported"`
+	Users    bool      `json:"users"`
+}
+
+type userRecord struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Created   time.Time `json:"created"`
+	Activated bool      `json:"activated"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+}
+
+type snippetRecord struct {
+	Type       string    `json:"type"`
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+	Author     *author   `json:"author"`
+}
+
+// "author" je "null" za "snippet"-e bez autora
+// "email" se izvozi i bez korisnika, kako bi se autor mogao pronaći u bazi u koju se uvozi
+type author struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+// "record" je jedan red prilikom uvoza - koja polja su popunjena zavisi od "Type"
+// "Content" je "pointer", kako bismo razlikovali izostavljen sadržaj od praznog
+type record struct {
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Created    time.Time `json:"created"`
+	Activated  bool      `json:"activated"`
+	Role       string    `json:"role"`
+	Disabled   bool      `json:"disabled"`
+	Title      string    `json:"title"`
+	Content    *string   `json:"content"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+	Author     *author   `json:"author"`
+}
+
+// "Export" upisuje sve "snippet"-e (i korisnike, ukoliko je "IncludeUsers" = "true") u "w"
+// zapisi se upisuju čim se pročitaju iz baze, pa se izvoz može slati direktno kroz HTTP odgovor
+func Export(ctx context.Context, w io.Writer, store models.TransferModelInterface, opts Options, logger *slog.Logger) (ExportStats, error) {
+	var stats ExportStats
+	enc := json.NewEncoder(w)
+	start := time.Now()
+
+	err := enc.Encode(headerRecord{Type: typeHeader, Version: Version, Exported: start.UTC(), Users: opts.IncludeUsers})
+	if err != nil {
+		return stats, err
+	}
+
+	if opts.IncludeUsers {
+		err = store.EachUser(ctx, func(u models.User) error {
+			stats.Users++
+			if stats.Users%progressEvery == 0 {
+				logger.Info("export progress", "users", stats.Users)
+			}
+
+			return enc.Encode(userRecord{
+				Type:      typeUser,
+				ID:        u.ID,
+				Name:      u.Name,
+				Email:     u.Email,
+				Created:   u.Created.UTC(),
+				Activated: u.Activated,
+				Role:      u.Role,
+				Disabled:  u.Disabled,
+			})
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	err = store.EachSnippet(ctx, func(s models.Snippet, authorEmail string) error {
+		stats.Snippets++
+		if stats.Snippets%progressEvery == 0 {
+			logger.Info("export progress", "snippets", stats.Snippets)
+		}
+
+		rec := snippetRecord{
+			Type:       typeSnippet,
+			ID:         s.ID,
+			Title:      s.Title,
+			Content:    s.Content,
+			Created:    s.Created.UTC(),
+			Expires:    s.Expires.UTC(),
+			Visibility: s.Visibility,
+		}
+		if s.UserID != 0 {
+			rec.Author = &author{ID: s.UserID, Email: authorEmail}
+		}
+
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	logger.Info("export finished", "users", stats.Users, "snippets", stats.Snippets, "duration", time.Since(start))
+	return stats, nil
+}
+
+// "Import" čita zapise iz "r" i upisuje ih u bazu, jedan po jedan
+// uvoz nije jedna transakcija - ukoliko se prekine, može se ponoviti, jer se već uvezeni zapisi preskaču
+// autor "snippet"-a se traži prvo među uvezenim korisnicima (po starom "ID"-ju), a zatim po "email" adresi
+func Import(ctx context.Context, r io.Reader, store models.TransferModelInterface, logger *slog.Logger) (ImportStats, error) {
+	stats := ImportStats{Users: map[string]int{}, Snippets: map[string]int{}}
+	dec := json.NewDecoder(r)
+	start := time.Now()
+
+	// stari "ID" korisnika -> "ID" u ovoj bazi, odnosno "email" -> "ID" (ili "0" ukoliko korisnik ne postoji)
+	userIDs := map[int]int{}
+	emails := map[string]int{}
+
+	for line := 1; ; line++ {
+		var rec record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("record %d: %w", line, err)
+		}
+
+		if line == 1 {
+			if rec.Type != typeHeader {
+				return stats, errors.New("record 1: missing header")
+			}
+			if rec.Version < 1 || rec.Version > Version {
+				return stats, fmt.Errorf("record 1: unsupported version %d", rec.Version)
+			}
+			continue
+		}
+
+		switch rec.Type {
+		case typeUser:
+			u, err := rec.user()
+			if err != nil {
+				return stats, fmt.Errorf("record %d: %w", line, err)
+			}
+
+			id, result, err := store.ImportUser(ctx, u)
+			if err != nil {
+				return stats, fmt.Errorf("record %d: %w", line, err)
+			}
+			userIDs[u.ID] = id
+			emails[u.Email] = id
+			stats.Users[result]++
+
+		case typeSnippet:
+			s, err := rec.snippet()
+			if err != nil {
+				return stats, fmt.Errorf("record %d: %w", line, err)
+			}
+
+			if rec.Author != nil {
+				s.UserID, err = resolveAuthor(ctx, store, *rec.Author, userIDs, emails)
+				if err != nil {
+					return stats, fmt.Errorf("record %d: %w", line, err)
+				}
+				if s.UserID == 0 {
+					stats.UnknownAuthors++
+				}
+			}
+
+			_, result, err := store.ImportSnippet(ctx, s)
+			if err != nil {
+				return stats, fmt.Errorf("record %d: %w", line, err)
+			}
+			stats.Snippets[result]++
+
+		default:
+			return stats, fmt.Errorf("record %d: unknown record type %q", line, rec.Type)
+		}
+
+		if (line-1)%progressEvery == 0 {
+			logger.Info("import progress", "records", line-1, "users", stats.Users, "snippets", stats.Snippets)
+		}
+	}
+
+	logger.Info("import finished", "users", stats.Users, "snippets", stats.Snippets, "unknown_authors", stats.UnknownAuthors, "duration", time.Since(start))
+	return stats, nil
+}
+
+// "resolveAuthor" vraća "0" ukoliko autor ne postoji u ovoj bazi
+func resolveAuthor(ctx context.Context, store models.TransferModelInterface, a author, userIDs map[int]int, emails map[string]int) (int, error) {
+	if id, ok := userIDs[a.ID]; ok {
+		return id, nil
+	}
+	if a.Email == "" {
+		return 0, nil
+	}
+	if id, ok := emails[a.Email]; ok {
+		return id, nil
+	}
+
+	id, err := store.UserIDByEmail(ctx, a.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	emails[a.Email] = id
+	return id, nil
+}
+
+func (rec record) user() (models.User, error) {
+	if rec.Email == "" || rec.Name == "" {
+		return models.User{}, errors.New("user must have a name and an email")
+	}
+
+	role := rec.Role
+	if role != models.RoleAdmin {
+		role = models.RoleUser
+	}
+
+	return models.User{
+		ID:        rec.ID,
+		Name:      rec.Name,
+		Email:     rec.Email,
+		Created:   rec.Created,
+		Activated: rec.Activated,
+		Role:      role,
+		Disabled:  rec.Disabled,
+	}, nil
+}
+
+func (rec record) snippet() (models.Snippet, error) {
+	if rec.Title == "" || rec.Content == nil || rec.Created.IsZero() || rec.Expires.IsZero() {
+		return models.Snippet{}, errors.New("snippet must have a title, content, created and expires")
+	}
+
+	// timovi se ne prenose, pa "snippet" tima postaje privatni "snippet" autora
+	visibility := rec.Visibility
+	if visibility != models.VisibilityPublic {
+		visibility = models.VisibilityPrivate
+	}
+
+	return models.Snippet{
+		ID:         rec.ID,
+		Title:      rec.Title,
+		Content:    *rec.Content,
+		Created:    rec.Created,
+		Expires:    rec.Expires,
+		Visibility: visibility,
+	}, nil
+}
@@ -0,0 +1,211 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"snippetbox.lazarmrkic.com/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "memoryStore" je "TransferModelInterface" u memoriji, sa istim pravilima za "ID" konflikte kao "models.TransferModel"
+type memoryStore struct {
+	users    []models.User
+	snippets []models.Snippet
+	emails   map[string]string
+}
+
+func (m *memoryStore) EachUser(ctx context.Context, fn func(models.User) error) error {
+	for _, u := range m.users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) EachSnippet(ctx context.Context, fn func(models.Snippet, string) error) error {
+	for _, s := range m.snippets {
+		email := ""
+		for _, u := range m.users {
+			if u.ID == s.UserID {
+				email = u.Email
+			}
+		}
+		if err := fn(s, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) UserIDByEmail(ctx context.Context, email string) (int, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u.ID, nil
+		}
+	}
+	return 0, models.ErrNoRecord
+}
+
+func (m *memoryStore) ImportUser(ctx context.Context, u models.User) (int, string, error) {
+	if id, err := m.UserIDByEmail(ctx, u.Email); err == nil {
+		return id, models.ImportExisting, nil
+	}
+
+	result := models.ImportInserted
+	maxID, taken := 0, false
+	for _, existing := range m.users {
+		maxID = max(maxID, existing.ID)
+		taken = taken || existing.ID == u.ID
+	}
+	if taken {
+		u.ID = maxID + 1
+		result = models.ImportRenumbered
+	}
+
+	m.users = append(m.users, u)
+	return u.ID, result, nil
+}
+
+func (m *memoryStore) ImportSnippet(ctx context.Context, s models.Snippet) (int, string, error) {
+	result := models.ImportInserted
+	maxID, taken := 0, false
+	for _, existing := range m.snippets {
+		if existing.Title == s.Title && existing.Content == s.Content && existing.Created.Equal(s.Created) {
+			return existing.ID, models.ImportExisting, nil
+		}
+		maxID = max(maxID, existing.ID)
+		taken = taken || existing.ID == s.ID
+	}
+	if taken {
+		s.ID = maxID + 1
+		result = models.ImportRenumbered
+	}
+
+	m.snippets = append(m.snippets, s)
+	return s.ID, result, nil
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestExportImport(t *testing.T) {
+	created := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	source := &memoryStore{
+		users: []models.User{
+			{ID: 1, Name: "Alice", Email: "alice@example.com", Created: created, Activated: true, Role: models.RoleAdmin},
+			{ID: 2, Name: "Bob", Email: "bob@example.com", Created: created},
+		},
+		snippets: []models.Snippet{
+			{ID: 1, Title: "O snail", Content: "Climb Mount Fuji", Created: created, Expires: created.Add(time.Hour), UserID: 1, Visibility: models.VisibilityPublic},
+			{ID: 2, Title: "Team", Content: "", Created: created, Expires: created.Add(time.Hour), UserID: 2, TeamID: 5, Visibility: models.VisibilityTeam},
+			{ID: 3, Title: "Anonymous", Content: "line\nbreak", Created: created, Expires: created, Visibility: models.VisibilityPublic},
+		},
+	}
+
+	var buf bytes.Buffer
+	exported, err := Export(context.Background(), &buf, source, Options{IncludeUsers: true}, discard)
+	assert.NilError(t, err)
+	assert.Equal(t, exported, ExportStats{Users: 2, Snippets: 3})
+	assert.Equal(t, strings.Count(buf.String(), "\n"), 6)
+
+	// ciljna baza već ima Boba (pod drugim "ID"-jem), drugog korisnika sa "ID"-jem 1 i drugi "snippet" sa "ID"-jem 2
+	target := &memoryStore{
+		users: []models.User{
+			{ID: 1, Name: "Carol", Email: "carol@example.com"},
+			{ID: 7, Name: "Bob", Email: "bob@example.com"},
+		},
+		snippets: []models.Snippet{
+			{ID: 2, Title: "Other", Content: "Other", Created: created},
+		},
+	}
+
+	imported, err := Import(context.Background(), bytes.NewReader(buf.Bytes()), target, discard)
+	assert.NilError(t, err)
+	assert.Equal(t, imported.Users[models.ImportRenumbered], 1)
+	assert.Equal(t, imported.Users[models.ImportExisting], 1)
+	// "snippet" 2 je zauzet, a "snippet" 3 dobija novi "ID" jer je "ID" 3 u međuvremenu dodijeljen "snippet"-u 2
+	assert.Equal(t, imported.Snippets[models.ImportInserted], 1)
+	assert.Equal(t, imported.Snippets[models.ImportRenumbered], 2)
+	assert.Equal(t, imported.UnknownAuthors, 0)
+
+	// Alice je dobila "ID" 8, pa i njen "snippet" mora pokazivati na novi "ID"
+	alice := target.users[2]
+	assert.Equal(t, alice.Email, "alice@example.com")
+	assert.Equal(t, alice.ID, 8)
+	assert.Equal(t, alice.Role, models.RoleAdmin)
+	assert.Equal(t, alice.Created.Equal(created), true)
+
+	snail := target.snippets[1]
+	assert.Equal(t, snail.ID, 1)
+	assert.Equal(t, snail.UserID, 8)
+	assert.Equal(t, snail.Expires.Equal(created.Add(time.Hour)), true)
+
+	// "snippet" tima postaje privatni "snippet" Boba, koji je već postojao
+	team := target.snippets[2]
+	assert.Equal(t, team.ID, 3)
+	assert.Equal(t, team.UserID, 7)
+	assert.Equal(t, team.TeamID, 0)
+	assert.Equal(t, team.Visibility, models.VisibilityPrivate)
+
+	// ponovljeni uvoz ne kreira duplikate
+	imported, err = Import(context.Background(), bytes.NewReader(buf.Bytes()), target, discard)
+	assert.NilError(t, err)
+	assert.Equal(t, imported.Snippets[models.ImportExisting], 3)
+	assert.Equal(t, imported.Users[models.ImportExisting], 2)
+}
+
+func TestImportWithoutUsers(t *testing.T) {
+	input := `{"type":"header","version":1,"users":false}
+{"type":"snippet","id":4,"title":"T","content":"C","created":"2024-01-02T15:04:05Z","expires":"2025-01-02T15:04:05Z","visibility":"public","author":{"id":9,"email":"alice@example.com"}}
+{"type":"snippet","id":5,"title":"T2","content":"C","created":"2024-01-02T15:04:05Z","expires":"2025-01-02T15:04:05Z","visibility":"public","author":{"id":10,"email":"nobody@example.com"}}
+`
+	target := &memoryStore{users: []models.User{{ID: 3, Name: "Alice", Email: "alice@example.com"}}}
+
+	imported, err := Import(context.Background(), strings.NewReader(input), target, discard)
+	assert.NilError(t, err)
+	assert.Equal(t, imported.UnknownAuthors, 1)
+	assert.Equal(t, target.snippets[0].UserID, 3)
+	assert.Equal(t, target.snippets[1].UserID, 0)
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Missing header",
+			input: `{"type":"snippet"}`,
+			want:  "record 1: missing header",
+		},
+		{
+			name:  "Newer version",
+			input: `{"type":"header","version":2}`,
+			want:  "record 1: unsupported version 2",
+		},
+		{
+			name:  "Unknown type",
+			input: "{\"type\":\"header\",\"version\":1}\n{\"type\":\"team\"}",
+			want:  `record 2: unknown record type "team"`,
+		},
+		{
+			name:  "Incomplete snippet",
+			input: "{\"type\":\"header\",\"version\":1}\n{\"type\":\"snippet\",\"title\":\"T\"}",
+			want:  "record 2: snippet must have a title, content, created and expires",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(context.Background(), strings.NewReader(tt.input), &memoryStore{}, discard)
+			assert.Equal(t, err != nil && err.Error() == tt.want, true)
+		})
+	}
+}
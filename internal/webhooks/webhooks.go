@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// paket "webhooks" potpisuje i isporučuje JSON događaje ka URL-ovima koje su korisnici registrovali
+// red za isporuku i evidencija pokušaja se čuvaju u bazi (vidjeti "models/webhooks.go") - ovdje je samo HTTP dio
+
+// zaglavlja koja se šalju uz svaki zahtjev
+const (
+	HeaderEvent     = "X-Snippetbox-Event"
+	HeaderDelivery  = "X-Snippetbox-Delivery"
+	HeaderSignature = "X-Snippetbox-Signature"
+)
+
+// prefiks tajnog ključa - olakšava prepoznavanje ključa, npr. u logovima ili repozitorijumima
+const secretPrefix = "whsec_"
+
+// "ErrInvalidSignature" vraća "Verify" kada potpis ne odgovara tijelu zahtjeva ili je prestar
+var ErrInvalidSignature = errors.New("webhooks: invalid signature")
+
+// "NewSecret" generiše nasumičan tajni ključ za potpisivanje
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// "Sign" vraća vrijednost "X-Snippetbox-Signature" zaglavlja u obliku "t=<unix>,v1=<hex>"
+// potpisuje se "<unix>.<tijelo>", pa primalac može da odbije stare (ponovljene) zahtjeve
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// "Verify" provjerava potpis iz zaglavlja - namijenjena je primaocima napisanim u Go-u (i testovima)
+// potpis stariji od "tolerance" se odbija
+func Verify(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret string, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// "Backoff" vraća čekanje prije sledećeg pokušaja, nakon "attempt" neuspješnih pokušaja
+// čekanje se duplira sa svakim pokušajem (30s, 1m, 2m, 4m...), ali nikad nije duže od "max"
+func Backoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+
+	return min(d, max)
+}
+
+// "ErrForbiddenAddress" se vraća kada URL vodi na adresu unutar lokalne ili privatne mreže
+var ErrForbiddenAddress = errors.New("webhooks: destination address is not allowed")
+
+// "sharedAddressSpace" (100.64.0.0/10) nije javna adresa, a neki "cloud" servisi na njoj drže "metadata" servis
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// "PublicAddr" vraća "false" za "loopback", privatne, "link-local" (npr. 169.254.169.254) i ostale adrese koje nisu javne
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// "NewClient" vraća HTTP klijenta za isporuku "webhook"-a
+// URL bira korisnik, pa klijent ne smije da se poveže na adrese unutar lokalne mreže (baza, "metadata" servis...)
+// adresa se provjerava prilikom same konekcije ("Control"), nakon DNS upita, pa je ne može zaobići ni DNS zapis koji vodi na "127.0.0.1"
+// redirekcije se ne prate - odgovor "3xx" je neuspješan pokušaj, kao i svaki drugi odgovor van "2xx"
+func NewClient(timeout time.Duration) *http.Client {
+	return newClient(timeout, PublicAddr)
+}
+
+func newClient(timeout time.Duration, allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// "proxy" iz okruženja bi se povezao umjesto nas, pa bi provjera adrese izgubila smisao
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// "Sender" šalje potpisane zahtjeve
+// "Client" mora imati "Timeout", kako spor primalac ne bi zauzeo "worker"-a (vidjeti "NewClient")
+type Sender struct {
+	Client    *http.Client
+	UserAgent string
+}
+
+// "Result" opisuje jedan pokušaj isporuke
+// "Status" je "0" ukoliko odgovor nije ni stigao (npr. greška u konekciji)
+type Result struct {
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+// "OK" vraća "true" za "2xx" odgovore - svi ostali ishodi se ponavljaju
+func (r Result) OK() bool {
+	return r.Err == nil && r.Status >= 200 && r.Status < 300
+}
+
+// "Send" šalje "payload" kao "POST" zahtjev na dati URL
+func (s *Sender) Send(ctx context.Context, url string, secret string, event string, deliveryID int, payload []byte) Result {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.UserAgent)
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(deliveryID))
+	req.Header.Set(HeaderSignature, Sign(secret, start, payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	// tijelo odgovora nas ne zanima, ali ga čitamo (do 64 KB), kako bi se konekcija mogla ponovo iskoristiti
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := Result{Status: resp.StatusCode, Duration: time.Since(start)}
+	if !result.OK() {
+		result.Err = fmt.Errorf("webhooks: unexpected response status %d", resp.StatusCode)
+	}
+
+	return result
+}
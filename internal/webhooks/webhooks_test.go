@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"snippetbox.lazarmrkic.com/internal/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2023, 3, 17, 10, 15, 0, 0, time.UTC)
+	body := []byte(`{"event":"snippet.created"}`)
+
+	header := Sign("whsec_test", now, body)
+	assert.Equal(t, strings.HasPrefix(header, "t=1679048100,v1="), true)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{name: "Valid", secret: "whsec_test", header: header, body: body, now: now, want: nil},
+		{name: "Within tolerance", secret: "whsec_test", header: header, body: body, now: now.Add(4 * time.Minute), want: nil},
+		{name: "Too old", secret: "whsec_test", header: header, body: body, now: now.Add(10 * time.Minute), want: ErrInvalidSignature},
+		{name: "Wrong secret", secret: "whsec_other", header: header, body: body, now: now, want: ErrInvalidSignature},
+		{name: "Modified body", secret: "whsec_test", header: header, body: []byte(`{"event":"snippet.expired"}`), now: now, want: ErrInvalidSignature},
+		{name: "Malformed header", secret: "whsec_test", header: "v1=abc", body: body, now: now, want: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute), tt.want)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+
+	for i, w := range want {
+		got := Backoff(i+1, 30*time.Second, 5*time.Minute)
+		if got != w {
+			t.Errorf("attempt %d: got %v; want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var gotBody []byte
+	var gotHeaders http.Header
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeaders = r.Header
+		if r.Header.Get(HeaderEvent) == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+
+	s := &Sender{Client: &http.Client{Timeout: time.Second}, UserAgent: "Snippetbox-Webhooks/1.0"}
+	payload := []byte(`{"event":"snippet.created"}`)
+
+	result := s.Send(context.Background(), ts.URL, "whsec_test", "snippet.created", 7, payload)
+	assert.Equal(t, result.OK(), true)
+	assert.Equal(t, result.Status, http.StatusOK)
+	assert.Equal(t, string(gotBody), string(payload))
+	assert.Equal(t, gotHeaders.Get(HeaderDelivery), "7")
+	assert.NilError(t, Verify("whsec_test", gotHeaders.Get(HeaderSignature), gotBody, time.Now(), time.Minute))
+
+	result = s.Send(context.Background(), ts.URL, "whsec_test", "fail", 8, payload)
+	assert.Equal(t, result.OK(), false)
+	assert.Equal(t, result.Status, http.StatusBadGateway)
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "::1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "100.100.100.200", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "::ffff:127.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, PublicAddr(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hooks", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("Private address is refused", func(t *testing.T) {
+		s := &Sender{Client: NewClient(time.Second)}
+
+		result := s.Send(context.Background(), ts.URL+"/hooks", "whsec_test", "snippet.created", 1, []byte(`{}`))
+		assert.Equal(t, errors.Is(result.Err, ErrForbiddenAddress), true)
+		assert.Equal(t, result.Status, 0)
+	})
+
+	t.Run("Redirects are not followed", func(t *testing.T) {
+		// "httptest" server je na "127.0.0.1", pa ovdje dozvoljavamo sve adrese
+		s := &Sender{Client: newClient(time.Second, func(netip.Addr) bool { return true })}
+
+		result := s.Send(context.Background(), ts.URL+"/redirect", "whsec_test", "snippet.created", 1, []byte(`{}`))
+		assert.Equal(t, result.OK(), false)
+		assert.Equal(t, result.Status, http.StatusFound)
+	})
+}
@@ -0,0 +1,217 @@
+// iscrtava dokumentaciju API-ja na osnovu "/api/openapi.json"
+// tekst iz specifikacije se uvijek upisuje preko "textContent", nikad kao HTML
+(function () {
+	var container = document.getElementById("api-docs");
+	if (!container) {
+		return;
+	}
+
+	var methods = ["get", "post", "put", "patch", "delete"];
+
+	function el(tag, text, className) {
+		var node = document.createElement(tag);
+		if (text !== undefined && text !== null) {
+			node.textContent = text;
+		}
+		if (className) {
+			node.className = className;
+		}
+		return node;
+	}
+
+	// "resolve" prati "$ref" reference, npr. "#/components/schemas/Snippet"
+	function resolve(spec, obj) {
+		while (obj && obj["$ref"]) {
+			var parts = obj["$ref"].replace(/^#\//, "").split("/");
+			obj = spec;
+			for (var i = 0; i < parts.length; i++) {
+				obj = obj[parts[i]];
+			}
+		}
+		return obj;
+	}
+
+	function refName(obj) {
+		if (obj && obj["$ref"]) {
+			return obj["$ref"].split("/").pop();
+		}
+		return "";
+	}
+
+	// "describeSchema" vraća kratak opis tipa i ograničenja, npr. "string, 1-100 characters"
+	function describeSchema(spec, schema) {
+		if (schema && schema.allOf && schema.allOf.length === 1) {
+			var merged = resolve(spec, schema.allOf[0]);
+			var text = refName(schema.allOf[0]) || describeSchema(spec, merged);
+			return schema.nullable ? text + " or null" : text;
+		}
+		if (refName(schema)) {
+			return refName(schema);
+		}
+		schema = resolve(spec, schema) || {};
+
+		var parts = [];
+		if (schema.type === "array") {
+			parts.push("array of " + describeSchema(spec, schema.items));
+		} else if (schema.type) {
+			parts.push(schema.format ? schema.type + " (" + schema.format + ")" : schema.type);
+		}
+		if (schema["enum"]) {
+			parts.push("one of: " + schema["enum"].join(", "));
+		}
+		if (schema.minLength !== undefined || schema.maxLength !== undefined) {
+			parts.push((schema.minLength || 0) + "-" + (schema.maxLength !== undefined ? schema.maxLength : "∞") + " characters");
+		}
+		if (schema.minimum !== undefined) {
+			parts.push("minimum " + schema.minimum);
+		}
+		if (schema["default"] !== undefined) {
+			parts.push("default " + schema["default"]);
+		}
+		if (schema.nullable) {
+			parts.push("nullable");
+		}
+		return parts.join(", ");
+	}
+
+	function fieldsTable(rows) {
+		var table = el("table");
+		var header = el("tr");
+		header.appendChild(el("th", "Name"));
+		header.appendChild(el("th", "Type"));
+		header.appendChild(el("th", "Description"));
+		table.appendChild(header);
+
+		rows.forEach(function (row) {
+			var tr = el("tr");
+			var name = el("td");
+			name.appendChild(el("code", row.name));
+			if (row.required) {
+				name.appendChild(el("span", " required", "required"));
+			}
+			tr.appendChild(name);
+			tr.appendChild(el("td", row.type));
+			tr.appendChild(el("td", row.description || ""));
+			table.appendChild(tr);
+		});
+		return table;
+	}
+
+	function schemaRows(spec, schema) {
+		schema = resolve(spec, schema) || {};
+		var required = schema.required || [];
+		return Object.keys(schema.properties || {}).map(function (name) {
+			var prop = schema.properties[name];
+			return {
+				name: name,
+				required: required.indexOf(name) !== -1,
+				type: describeSchema(spec, prop),
+				description: prop.description
+			};
+		});
+	}
+
+	function renderOperation(spec, path, method, op, pathParams) {
+		var section = el("section", null, "api-operation");
+
+		var heading = el("h3");
+		heading.appendChild(el("span", method.toUpperCase(), "api-method api-method-" + method));
+		heading.appendChild(el("code", " " + path));
+		section.appendChild(heading);
+
+		if (op.summary) {
+			section.appendChild(el("p", op.summary, "api-summary"));
+		}
+		if (op.description) {
+			section.appendChild(el("p", op.description));
+		}
+		if (op["x-required-scope"]) {
+			section.appendChild(el("p", "Required token scope: " + op["x-required-scope"]));
+		} else if (op.security && op.security.length === 0) {
+			section.appendChild(el("p", "No authentication required."));
+		}
+
+		var params = (pathParams || []).concat(op.parameters || []);
+		if (params.length) {
+			section.appendChild(el("h4", "Parameters"));
+			section.appendChild(fieldsTable(params.map(function (p) {
+				return {
+					name: p.name + " (" + p["in"] + ")",
+					required: p.required,
+					type: describeSchema(spec, p.schema),
+					description: p.description
+				};
+			})));
+		}
+
+		if (op.requestBody) {
+			var body = op.requestBody.content["application/json"].schema;
+			section.appendChild(el("h4", "Request body (" + (refName(body) || "JSON") + ")"));
+			var bodySchema = resolve(spec, body);
+			if (bodySchema.additionalProperties === false) {
+				section.appendChild(el("p", "Unknown keys are rejected."));
+			}
+			section.appendChild(fieldsTable(schemaRows(spec, body)));
+		}
+
+		section.appendChild(el("h4", "Responses"));
+		var list = el("ul");
+		Object.keys(op.responses).sort().forEach(function (status) {
+			var response = resolve(spec, op.responses[status]);
+			var text = status + " - " + response.description;
+			if (response.content) {
+				var schema = response.content["application/json"].schema;
+				var name = refName(schema);
+				if (name) {
+					text += " (" + name + ")";
+				}
+			}
+			list.appendChild(el("li", text));
+		});
+		section.appendChild(list);
+
+		return section;
+	}
+
+	function render(spec) {
+		container.textContent = "";
+
+		if (spec.info && spec.info.description) {
+			container.appendChild(el("p", spec.info.description));
+		}
+
+		Object.keys(spec.paths).sort().forEach(function (path) {
+			var item = spec.paths[path];
+			methods.forEach(function (method) {
+				if (item[method]) {
+					container.appendChild(renderOperation(spec, path, method, item[method], item.parameters));
+				}
+			});
+		});
+
+		container.appendChild(el("h3", "Schemas"));
+		var schemas = spec.components.schemas;
+		Object.keys(schemas).sort().forEach(function (name) {
+			container.appendChild(el("h4", name));
+			var rows = schemaRows(spec, schemas[name]);
+			if (rows.length) {
+				container.appendChild(fieldsTable(rows));
+			} else {
+				container.appendChild(el("p", describeSchema(spec, schemas[name])));
+			}
+		});
+	}
+
+	fetch("/api/openapi.json", {headers: {"Accept": "application/json"}})
+		.then(function (response) {
+			if (!response.ok) {
+				throw new Error("HTTP " + response.status);
+			}
+			return response.json();
+		})
+		.then(render)
+		.catch(function (err) {
+			container.textContent = "";
+			container.appendChild(el("p", "The API documentation couldn't be loaded: " + err.message, "error"));
+		});
+})();
@@ -5,4 +5,61 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// početna stranica dobija nove javne "snippet"-e preko "/events" (Server-Sent Events), bez osvježavanja
+// tabela prikazuje najviše 10 "snippet"-a, kao i "Latest" upit na serveru
+var latestLimit = 10;
+
+function snippetRow(snippet) {
+	var row = document.createElement("tr");
+
+	var title = document.createElement("td");
+	var titleLink = document.createElement("a");
+	titleLink.href = "/snippet/view/" + snippet.id;
+	titleLink.textContent = snippet.title;
+	title.appendChild(titleLink);
+
+	var author = document.createElement("td");
+	if (snippet.author) {
+		var authorLink = document.createElement("a");
+		authorLink.href = "/user/profile/" + snippet.author.id;
+		authorLink.textContent = snippet.author.name;
+		author.appendChild(authorLink);
+	} else {
+		author.textContent = "Anonymous";
+	}
+
+	var created = document.createElement("td");
+	created.textContent = snippet.created;
+
+	var id = document.createElement("td");
+	id.textContent = "#" + snippet.id;
+
+	row.append(title, author, created, id);
+	return row;
+}
+
+var latest = document.getElementById("latest-snippets");
+var noSnippets = document.getElementById("no-snippets");
+if ((latest || noSnippets) && window.EventSource) {
+	var events = new EventSource("/events");
+	events.addEventListener("snippet.created", function(e) {
+		// prazna stranica nema tabelu, pa je najjednostavnije učitati je ponovo
+		if (!latest) {
+			window.location.reload();
+			return;
+		}
+
+		var snippet = JSON.parse(e.data);
+		var rows = latest.querySelectorAll("tr");
+		var header = rows[0];
+		header.parentNode.insertBefore(snippetRow(snippet), header.nextSibling);
+
+		// prvi red je zaglavlje tabele
+		rows = latest.querySelectorAll("tr");
+		for (var i = rows.length - 1; i > latestLimit; i--) {
+			rows[i].remove();
+		}
+	});
+}